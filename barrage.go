@@ -0,0 +1,121 @@
+package e2e
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// BarrageOption configures Barrage.
+type BarrageOption func(*barrageConfig)
+
+type barrageConfig struct {
+	goroutines int
+	iterations int
+}
+
+// Goroutines sets the number of goroutines sending requests. The default is
+// 8.
+func Goroutines(n int) BarrageOption {
+	return func(c *barrageConfig) {
+		c.goroutines = n
+	}
+}
+
+// Iterations sets the number of requests each goroutine sends. The default
+// is 50.
+func Iterations(n int) BarrageOption {
+	return func(c *barrageConfig) {
+		c.iterations = n
+	}
+}
+
+// Barrage sends the requests built by newRequest to the router from several
+// goroutines at once, to surface data races in shared handler state under
+// -race. The goroutines start together once all of them are ready, and send
+// a fixed number of requests each, so the run takes a bounded time. i is the
+// index of the request across all goroutines. All requests are served by the
+// router of the first one. Barrage fails on 5xx responses and panics, and
+// logs the number of responses per status code.
+func Barrage(t *testing.T, newRequest func(i int) *http.Request, opts ...BarrageOption) {
+	t.Helper()
+
+	defaultRunner().Barrage(t, newRequest, opts...)
+}
+
+// Barrage is like Barrage but sends the requests with rn. Over a socket or to
+// a live server, panics surface as transport errors.
+func (rn *Runner) Barrage(t *testing.T, newRequest func(i int) *http.Request, opts ...BarrageOption) {
+	t.Helper()
+
+	cfg := barrageConfig{goroutines: 8, iterations: 50}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var (
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+		failures []string
+	)
+	fail := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	// Resolve the transport once, since routerFor may not be called from
+	// other goroutines than the test.
+	send := rn.sender(t, newRequest(0))
+
+	var ready, done sync.WaitGroup
+	start := make(chan struct{})
+	for g := range cfg.goroutines {
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			ready.Done()
+			<-start
+
+			for n := range cfg.iterations {
+				i := g*cfg.iterations + n
+				r := newRequest(i)
+				got, err := func() (got *http.Response, err error) {
+					defer func() {
+						if p := recover(); p != nil {
+							err = fmt.Errorf("panicked: %v", p)
+						}
+					}()
+					return send(r)
+				}()
+				if err != nil {
+					fail("request %d %s %s: %v", i, r.Method, r.URL, err)
+					continue
+				}
+				if got.StatusCode >= http.StatusInternalServerError {
+					fail("request %d %s %s: HTTP StatusCode: %d", i, r.Method, r.URL, got.StatusCode)
+				}
+				mu.Lock()
+				statuses[got.StatusCode]++
+				mu.Unlock()
+			}
+		}()
+	}
+	ready.Wait()
+	close(start)
+	done.Wait()
+
+	var summary []string
+	for _, code := range slices.Sorted(maps.Keys(statuses)) {
+		summary = append(summary, fmt.Sprintf("%d: %d", code, statuses[code]))
+	}
+	t.Logf("barrage of %d goroutines x %d requests: %s\n", cfg.goroutines, cfg.iterations, strings.Join(summary, ", "))
+	for _, f := range failures {
+		t.Error(f)
+	}
+}
@@ -0,0 +1,46 @@
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// bodyless reports whether responses of the status code never have a body,
+// so that filters decoding bodies skip them.
+func bodyless(code int) bool {
+	return code >= 100 && code < 200 || code == http.StatusNoContent || code == http.StatusNotModified
+}
+
+// checkEmptyResponse returns the violations of the empty-body semantics of
+// RFC 9110 by the 204 or 304 response r: neither may have a body, and a 204
+// response has no content to describe with Content-Length or Content-Type.
+// A 304 response may keep them, as they describe the cached representation.
+func checkEmptyResponse(t *testing.T, r *http.Response) []string {
+	t.Helper()
+
+	if r.StatusCode != http.StatusNoContent && r.StatusCode != http.StatusNotModified {
+		return nil
+	}
+
+	var rc io.ReadCloser
+	rc, r.Body = drainBody(t, r.Body)
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var violations []string
+	if len(body) > 0 {
+		violations = append(violations, fmt.Sprintf("HTTP %d response has a body of %d bytes\n", r.StatusCode, len(body)))
+	}
+	if r.StatusCode == http.StatusNoContent {
+		for _, key := range []string{"Content-Length", "Content-Type"} {
+			if v := r.Header.Get(key); v != "" {
+				violations = append(violations, fmt.Sprintf("HTTP 204 response has %s: %s\n", key, v))
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,142 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// CacheClass is the caching behavior expected from an endpoint.
+type CacheClass int
+
+const (
+	// CacheStatic endpoints serve responses cacheable by shared caches:
+	// Cache-Control has a positive max-age or s-maxage and neither no-store
+	// nor private, and ETag or Last-Modified allows revalidation.
+	CacheStatic CacheClass = iota + 1
+	// CacheDynamic endpoints serve responses which must not be reused
+	// without revalidation: Cache-Control has no-store, no-cache, private or
+	// max-age=0.
+	CacheDynamic
+)
+
+func (c CacheClass) String() string {
+	switch c {
+	case CacheStatic:
+		return "static"
+	case CacheDynamic:
+		return "dynamic"
+	default:
+		return fmt.Sprintf("CacheClass(%d)", int(c))
+	}
+}
+
+// CachePolicy maps the endpoints matched by patterns in the syntax of
+// http.ServeMux, e.g. "GET /assets/", to their CacheClass.
+type CachePolicy map[string]CacheClass
+
+// CacheContract is a ResponseFilter asserting that the caching headers of
+// successful responses to GET and HEAD requests follow the class of their
+// endpoint in policy. Responses of any endpoint must have consistent
+// headers: Cache-Control must not combine no-store with max-age, ETag must
+// be quoted and Last-Modified must be an HTTP date not in the future. Apply
+// it to every request with RegisterFilter or WithFilters, so that caching is
+// part of the tested contract:
+//
+//	e2e.RegisterFilter(e2e.CacheContract(e2e.CachePolicy{
+//		"GET /assets/":  e2e.CacheStatic,
+//		"GET /v1/user/": e2e.CacheDynamic,
+//	}))
+func CacheContract(policy CachePolicy) ResponseFilter {
+	mux := http.NewServeMux()
+	for pattern := range policy {
+		mux.Handle(pattern, http.NotFoundHandler())
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if r.StatusCode < 200 || r.StatusCode >= 300 || r.Request == nil {
+			return
+		}
+		if r.Request.Method != http.MethodGet && r.Request.Method != http.MethodHead {
+			return
+		}
+		var class CacheClass
+		if _, pattern := mux.Handler(r.Request); pattern != "" {
+			class = policy[pattern]
+		}
+		for _, v := range cacheViolations(r.Header, class, time.Now()) {
+			errorf(t, r, "CacheContract: %s %s: %s\n", r.Request.Method, r.Request.URL.Path, v)
+		}
+	}
+}
+
+// cacheViolations returns how the caching headers h break the contract of
+// class.
+func cacheViolations(h http.Header, class CacheClass, now time.Time) []string {
+	var violations []string
+	directives := parseCacheControl(h.Values("Cache-Control"))
+	maxAge, hasMaxAge := directives["max-age"]
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	_, private := directives["private"]
+	sMaxAge, hasSMaxAge := directives["s-maxage"]
+
+	if noStore && (hasMaxAge && maxAge != "0" || hasSMaxAge && sMaxAge != "0") {
+		violations = append(violations, fmt.Sprintf("Cache-Control %q combines no-store with a max-age", h.Get("Cache-Control")))
+	}
+	etag := h.Get("ETag")
+	if opaque := strings.TrimPrefix(etag, "W/"); etag != "" && (len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"') {
+		violations = append(violations, fmt.Sprintf("ETag %q is not quoted", etag))
+	}
+	lastModified := h.Get("Last-Modified")
+	if lastModified != "" {
+		if t, err := http.ParseTime(lastModified); err != nil {
+			violations = append(violations, fmt.Sprintf("Last-Modified %q is not an HTTP date", lastModified))
+		} else if t.After(now) {
+			violations = append(violations, fmt.Sprintf("Last-Modified %q is in the future", lastModified))
+		}
+	}
+
+	switch class {
+	case CacheStatic:
+		if !positiveAge(maxAge) && !positiveAge(sMaxAge) {
+			violations = append(violations, fmt.Sprintf("static endpoint without a positive max-age in Cache-Control %q", h.Get("Cache-Control")))
+		}
+		if noStore || private {
+			violations = append(violations, fmt.Sprintf("static endpoint not cacheable by shared caches with Cache-Control %q", h.Get("Cache-Control")))
+		}
+		if etag == "" && lastModified == "" {
+			violations = append(violations, "static endpoint without ETag or Last-Modified")
+		}
+	case CacheDynamic:
+		if !noStore && !noCache && !private && maxAge != "0" {
+			violations = append(violations, fmt.Sprintf("dynamic endpoint cacheable with Cache-Control %q", h.Get("Cache-Control")))
+		}
+	}
+	return violations
+}
+
+// parseCacheControl returns the directives of the Cache-Control header
+// values keyed by lowercase name.
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, v := range values {
+		for _, d := range strings.Split(v, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func positiveAge(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
@@ -0,0 +1,131 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"math/big"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// CanonicalJSON is a ResponseFilter re-serializing JSON bodies with sorted
+// keys, two-space indentation, unescaped HTML characters and numbers in one
+// format, e.g. 1.50 and 15e-1 as 1.5, so that differences between encoders
+// never show up in golden files. Unlike PrettyJSON, bodies of any JSON value
+// are accepted, and responses which are not JSON are left as is, so that it
+// can be applied to every response with RegisterFilter.
+func CanonicalJSON(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if bodyless(r.StatusCode) || !isJSON(r.Header.Get("Content-Type")) {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("CanonicalJSON: %v\n", err)
+	}
+	var buf bytes.Buffer
+	writeCanonicalJSON(&buf, v, "")
+	r.Body = io.NopCloser(&buf)
+}
+
+// isJSON reports whether the media type of Content-Type ct is JSON, e.g.
+// application/json or application/problem+json.
+func isJSON(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// writeCanonicalJSON writes v decoded with json.Decoder.UseNumber to buf
+// indented from the depth of indent.
+func writeCanonicalJSON(buf *bytes.Buffer, v any, indent string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for i, k := range slices.Sorted(maps.Keys(v)) {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + "  ")
+			writeJSONString(buf, k)
+			buf.WriteString(": ")
+			writeCanonicalJSON(buf, v[k], indent+"  ")
+		}
+		buf.WriteString("\n" + indent + "}")
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + "  ")
+			writeCanonicalJSON(buf, e, indent+"  ")
+		}
+		buf.WriteString("\n" + indent + "]")
+	case json.Number:
+		buf.WriteString(canonicalNumber(v))
+	case string:
+		writeJSONString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	}
+}
+
+// writeJSONString writes s as a JSON string without escaping HTML
+// characters.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	// Encode ends the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
+
+// canonicalNumber formats n in one format: integers in decimal without
+// fraction or exponent at any magnitude, and other numbers in the shortest
+// representation of their float64 value.
+func canonicalNumber(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return i.String()
+		}
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return s
+	}
+	if f == float64(int64(f)) && f > -1e15 && f < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,129 @@
+package e2e
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CaptureOption configures CaptureResponse.
+type CaptureOption func(*captureConfig)
+
+type captureConfig struct {
+	disallowUnknownFields bool
+	requireFields         bool
+}
+
+// DisallowUnknownFields makes CaptureResponse fail when the response
+// contains a field that the target type does not have, so that drift of the
+// client model is detected.
+func DisallowUnknownFields() CaptureOption {
+	return func(c *captureConfig) {
+		c.disallowUnknownFields = true
+	}
+}
+
+// RequireFields makes CaptureResponse fail when the response lacks a field
+// of the target type. Fields tagged with omitempty or omitzero are optional.
+func RequireFields() CaptureOption {
+	return func(c *captureConfig) {
+		c.requireFields = true
+	}
+}
+
+// missingFields returns the paths of the required fields of typ missing in
+// v, which is decoded from JSON.
+func missingFields(typ reflect.Type, v any, path string) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var missing []string
+	switch typ.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		for _, f := range structFields(typ) {
+			sub, ok := lookupField(obj, f.name)
+			if !ok {
+				if !f.optional {
+					missing = append(missing, joinPath(path, f.name))
+				}
+				continue
+			}
+			missing = append(missing, missingFields(f.typ, sub, joinPath(path, f.name))...)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		for i, elem := range arr {
+			missing = append(missing, missingFields(typ.Elem(), elem, path+"#"+strconv.Itoa(i))...)
+		}
+	}
+	return missing
+}
+
+// lookupField looks up name in obj case-insensitively like encoding/json,
+// preferring an exact match.
+func lookupField(obj map[string]any, name string) (any, bool) {
+	if v, ok := obj[name]; ok {
+		return v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type jsonField struct {
+	name     string
+	typ      reflect.Type
+	optional bool
+}
+
+// structFields returns the JSON fields of typ following the rules of
+// encoding/json, including the fields of embedded structs.
+func structFields(typ reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, structFields(ft)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		optional := false
+		for _, opt := range strings.Split(opts, ",") {
+			optional = optional || opt == "omitempty" || opt == "omitzero"
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type, optional: optional})
+	}
+	return fields
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
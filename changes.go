@@ -0,0 +1,158 @@
+package e2e
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var goldenChanges = flag.String("golden-changes", "", "write a summary of the golden files changed by -golden as JSON to the file when RunSuite returns")
+
+// GoldenChange summarizes the semantic changes of a golden file updated by
+// -golden, designed for PR bots to make golden churn reviewable. The changes
+// are written to the file given by -golden-changes when RunSuite returns, so
+// the flag has no effect in suites whose TestMain calls m.Run directly.
+type GoldenChange struct {
+	Golden string `json:"golden"`
+	// Kind is "added" or "modified".
+	Kind           string   `json:"kind"`
+	OldStatus      int      `json:"old_status,omitempty"`
+	NewStatus      int      `json:"new_status"`
+	HeadersAdded   []string `json:"headers_added,omitempty"`
+	HeadersRemoved []string `json:"headers_removed,omitempty"`
+	HeadersChanged []string `json:"headers_changed,omitempty"`
+	FieldsAdded    []string `json:"fields_added,omitempty"`
+	FieldsRemoved  []string `json:"fields_removed,omitempty"`
+	FieldsChanged  []string `json:"fields_changed,omitempty"`
+	// BodyChanged reports whether a body which is not JSON changed.
+	BodyChanged bool `json:"body_changed,omitempty"`
+}
+
+var changes struct {
+	mu      sync.Mutex
+	changes []GoldenChange
+}
+
+// recordGoldenChange records the change of the golden file filename from
+// its current content to data.
+func recordGoldenChange(t *testing.T, filename string, data []byte) {
+	t.Helper()
+
+	if *goldenChanges == "" {
+		return
+	}
+	old, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if old != nil {
+		if old, _, err = decodeGolden(old); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+	}
+	if slices.Equal(old, data) {
+		return
+	}
+
+	c := GoldenChange{Golden: filename, Kind: "added"}
+	newCode, newHeader, newBody, _ := parseDump(data)
+	c.NewStatus = newCode
+	if old != nil {
+		c.Kind = "modified"
+		oldCode, oldHeader, oldBody, _ := parseDump(old)
+		c.OldStatus = oldCode
+		c.HeadersAdded, c.HeadersRemoved, c.HeadersChanged = diffKeys(flattenHeader(oldHeader), flattenHeader(newHeader))
+
+		var oldJSON, newJSON any
+		if json.Unmarshal(oldBody, &oldJSON) == nil && json.Unmarshal(newBody, &newJSON) == nil {
+			c.FieldsAdded, c.FieldsRemoved, c.FieldsChanged = diffKeys(flattenJSON(oldJSON, ""), flattenJSON(newJSON, ""))
+		} else {
+			c.BodyChanged = !slices.Equal(oldBody, newBody)
+		}
+	}
+
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+	changes.changes = append(changes.changes, c)
+}
+
+func writeGoldenChanges(filename string) error {
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	slices.SortFunc(changes.changes, func(a, b GoldenChange) int {
+		return strings.Compare(a.Golden, b.Golden)
+	})
+	data, err := json.MarshalIndent(struct {
+		RunID   string         `json:"run_id"`
+		Changes []GoldenChange `json:"changes"`
+	}{
+		RunID:   RunID(),
+		Changes: changes.changes,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o600)
+}
+
+func flattenHeader(h map[string][]string) map[string]any {
+	m := make(map[string]any, len(h))
+	for k, v := range h {
+		m[k] = v
+	}
+	return m
+}
+
+// flattenJSON returns the leaves of v keyed by their dot-separated paths.
+func flattenJSON(v any, path string) map[string]any {
+	m := make(map[string]any)
+	switch v := v.(type) {
+	case map[string]any:
+		for k, sub := range v {
+			for p, leaf := range flattenJSON(sub, joinPath(path, k)) {
+				m[p] = leaf
+			}
+		}
+	case []any:
+		for i, sub := range v {
+			for p, leaf := range flattenJSON(sub, fmt.Sprintf("%s#%d", path, i)) {
+				m[p] = leaf
+			}
+		}
+	default:
+		m[path] = v
+	}
+	return m
+}
+
+// diffKeys returns the sorted keys added to, removed from and changed
+// between old and new.
+func diffKeys(old, new map[string]any) (added, removed, changed []string) {
+	for k, v := range new {
+		o, ok := old[k]
+		switch {
+		case !ok:
+			added = append(added, k)
+		case !reflect.DeepEqual(o, v):
+			changed = append(changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+	return added, removed, changed
+}
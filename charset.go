@@ -0,0 +1,48 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"testing"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// TranscodeCharset is a ResponseFilter transcoding bodies of the charset in
+// Content-Type, e.g. Shift_JIS or ISO-8859-1, to UTF-8, so that golden files
+// are readable and diffable. The charset of Content-Type is replaced with
+// utf-8, and the original charset is annotated in the X-E2e-Transcoded-From
+// header. Bodies without a charset or already in UTF-8 are left as is.
+func TranscodeCharset(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return
+	}
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		t.Fatalf("TranscodeCharset: %v\n", err)
+	}
+	charset, ok := params["charset"]
+	if !ok {
+		return
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		t.Fatalf("TranscodeCharset: unknown charset %q\n", charset)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return
+	}
+	body, err := io.ReadAll(enc.NewDecoder().Reader(r.Body))
+	if err != nil {
+		t.Fatalf("TranscodeCharset: decoding %s: %v\n", charset, err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	params["charset"] = "utf-8"
+	r.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	r.Header.Set("X-E2e-Transcoded-From", charset)
+}
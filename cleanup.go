@@ -0,0 +1,74 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Cleanup removes a resource created by Create, e.g. by sending a DELETE
+// request or deleting database rows. rn is the Runner which created the
+// resource, so that requests removing it reach the same handler or server.
+type Cleanup func(t *testing.T, rn *Runner)
+
+// Create runs RunTest with a request creating a resource and, once the
+// status code matches want, registers cleanup to remove the resource when t
+// and all its subtests complete, so that tests against shared environments
+// do not leak state. The resource is removed even when the response fails
+// the filters or the golden file. cleanup runs after the test, so it can use
+// the values captured by CaptureResponse.
+func Create(t *testing.T, r *http.Request, want int, cleanup Cleanup, filters ...ResponseFilter) {
+	t.Helper()
+
+	defaultRunner().Create(t, r, want, cleanup, filters...)
+}
+
+// Create is like Create but sends r with rn.
+func (rn *Runner) Create(t *testing.T, r *http.Request, want int, cleanup Cleanup, filters ...ResponseFilter) {
+	t.Helper()
+
+	rn.create(t, t, r, want, cleanup, filters)
+}
+
+// create runs RunTest of rn for t and registers cleanup to owner when the
+// status code matches want. The status code is checked as soon as the
+// response is received, before anything which may stop the test, and only
+// for the actual response, not the one of the shadow handler.
+func (rn *Runner) create(t, owner *testing.T, r *http.Request, want int, cleanup Cleanup, filters []ResponseFilter) {
+	t.Helper()
+
+	rn.runTest(t, r, want, filters, func(got *http.Response) {
+		if got.StatusCode == want {
+			owner.Cleanup(func() { cleanup(owner, rn) })
+		}
+	})
+}
+
+// Create is like Create but removes the resource when the whole scenario
+// completes instead of the current step, so that later steps can use it.
+// The request is sent with the Runner set by UseRunner.
+func (s *Scenario) Create(t *testing.T, r *http.Request, want int, cleanup Cleanup, filters ...ResponseFilter) {
+	t.Helper()
+
+	rn := s.runner
+	if rn == nil {
+		rn = defaultRunner()
+	}
+	rn.create(t, s.t, r, want, cleanup, filters)
+}
+
+// DeleteOnCleanup returns a Cleanup sending a DELETE request to the endpoint
+// returned by endpoint with the Runner which created the resource, e.g. to
+// the live server set by Remote. endpoint is called at cleanup time, so it
+// can refer to an ID captured from the creation response. The cleanup fails
+// unless the response is 2xx or 404.
+func DeleteOnCleanup(endpoint func() string, options ...RequestOption) Cleanup {
+	return func(t *testing.T, rn *Runner) {
+		t.Helper()
+
+		r := NewRequest(http.MethodDelete, endpoint(), nil, options...)
+		got := rn.serve(t, r)
+		if code := got.StatusCode; code != http.StatusNotFound && (code < 200 || code > 299) {
+			t.Errorf("cleanup %s %s: HTTP StatusCode: %d\n", r.Method, r.URL, code)
+		}
+	}
+}
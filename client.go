@@ -0,0 +1,281 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Client sends requests built by NewRequest to a server over a real
+// connection instead of calling the router directly.
+type Client struct {
+	baseURL *url.URL
+	client  *http.Client
+	write   throttle
+	read    throttle
+	trace   *Trace
+	close   bool
+	expect  bool
+	interim bool
+	cache   *ResponseCache
+	// remote reports whether the client sends requests to a live server
+	// tested with the golden files recorded in process.
+	remote bool
+}
+
+// Trace records the connection used for the last request sent by a Client.
+type Trace struct {
+	// Reused reports whether the connection was used for a previous request.
+	Reused bool
+	// WasIdle reports whether the connection was taken from the idle pool.
+	WasIdle bool
+	// LocalAddr is the client side address of the connection, which tells
+	// connections apart across requests.
+	LocalAddr string
+	// Interim is the informational (1xx) responses received before the
+	// final response, in order.
+	Interim []Interim
+	// BodySent reports whether the client started writing the request body.
+	BodySent bool
+}
+
+// Interim is an informational (1xx) response.
+type Interim struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+type throttle struct {
+	size     int
+	interval time.Duration
+}
+
+// ThrottleWrite makes the client write the request body size bytes at a
+// time, waiting interval between each write.
+func ThrottleWrite(size int, interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.write = throttle{size: size, interval: interval}
+	}
+}
+
+// ThrottleRead makes the client read the response body size bytes at a
+// time, waiting interval between each read.
+func ThrottleRead(size int, interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.read = throttle{size: size, interval: interval}
+	}
+}
+
+// WithTrace makes the client record the connection of every request to tr,
+// e.g. to assert that scenario steps share a keep-alive connection.
+func WithTrace(tr *Trace) ClientOption {
+	return func(c *Client) {
+		c.trace = tr
+	}
+}
+
+// NewConnections makes the client close the connection after every request,
+// so that each request is sent over a new connection.
+func NewConnections() ClientOption {
+	return func(c *Client) {
+		c.close = true
+	}
+}
+
+// ExpectContinue makes the client send "Expect: 100-continue" with request
+// bodies and wait up to timeout for the server to accept the headers before
+// writing the body. Record a Trace to see whether the body was sent.
+func ExpectContinue(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.expect = true
+		tr := c.client.Transport.(*http.Transport).Clone()
+		tr.ExpectContinueTimeout = timeout
+		c.client = &http.Client{Transport: tr}
+	}
+}
+
+// CaptureInterim makes RunTest write the informational (1xx) responses, such
+// as 103 Early Hints, to the golden file before the final response.
+func CaptureInterim() ClientOption {
+	return func(c *Client) {
+		c.interim = true
+	}
+}
+
+// NewClient returns a Client sending requests to srv.
+func NewClient(srv *httptest.Server, opts ...ClientOption) *Client {
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		panic(err)
+	}
+	c := &Client{
+		baseURL: u,
+		client:  srv.Client(),
+	}
+	if p := srv.Config.Protocols; srv.TLS == nil && p != nil && p.UnencryptedHTTP2() {
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		tr.Protocols = new(http.Protocols)
+		tr.Protocols.SetUnencryptedHTTP2(true)
+		c.client = &http.Client{Transport: tr}
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Do sends r and reads the whole response body. Unlike RunTest, it returns
+// transport errors so that tests can assert how the server handles slow or
+// broken clients.
+func (c *Client) Do(r *http.Request) (*http.Response, error) {
+	got, _, err := c.do(r)
+	return got, err
+}
+
+func (c *Client) do(r *http.Request) (*http.Response, *Trace, error) {
+	trace := new(Trace)
+	ctx := httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.Reused = info.Reused
+			trace.WasIdle = info.WasIdle
+			trace.LocalAddr = info.Conn.LocalAddr().String()
+		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			trace.Interim = append(trace.Interim, Interim{StatusCode: code, Header: http.Header(header)})
+			return nil
+		},
+	})
+	if c.trace != nil {
+		defer func() { *c.trace = *trace }()
+	}
+
+	req := r.Clone(ctx)
+	req.RequestURI = ""
+	req.Close = c.close
+	req.URL.Scheme = c.baseURL.Scheme
+	req.URL.Host = c.baseURL.Host
+	var key string
+	if c.cache != nil {
+		k, ok, err := cacheKey(req)
+		if err != nil {
+			return nil, trace, err
+		}
+		if ok {
+			if resp, ok := c.cache.load(k, req); ok {
+				return resp, trace, nil
+			}
+			key = k
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if c.expect {
+			req.Header.Set("Expect", "100-continue")
+		}
+		if c.write.size > 0 {
+			req.Body = io.NopCloser(&throttledReader{r: req.Body, throttle: c.write})
+		}
+		req.Body = &sentBody{ReadCloser: req.Body, trace: trace}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, trace, err
+	}
+	defer resp.Body.Close()
+
+	var src io.Reader = resp.Body
+	if c.read.size > 0 {
+		src = &throttledReader{r: resp.Body, throttle: c.read}
+	}
+	body, err := io.ReadAll(src)
+	if err != nil {
+		return nil, trace, err
+	}
+	if key != "" {
+		c.cache.store(key, resp, body)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, trace, nil
+}
+
+// open sends r and returns as soon as the response headers arrive, leaving
+// the body to be read as the server streams it. The cache and the throttles
+// do not apply.
+func (c *Client) open(r *http.Request) (*http.Response, error) {
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	req.Close = c.close
+	req.URL.Scheme = c.baseURL.Scheme
+	req.URL.Host = c.baseURL.Host
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Date changes on every request, as in RunTest.
+	resp.Header.Del("Date")
+	resp.Request = r
+	return resp, nil
+}
+
+// RunTest is like RunTest but sends r through the client.
+func (c *Client) RunTest(t *testing.T, r *http.Request, want int, filters ...ResponseFilter) {
+	t.Helper()
+
+	c.runTest(t, defaultRunner(), r, want, filters, nil)
+}
+
+func (c *Client) runTest(t *testing.T, rn *Runner, r *http.Request, want int, filters []ResponseFilter, served func(*http.Response)) {
+	t.Helper()
+
+	runTest(t, rn, r, want, filters, served, func(r *http.Request) (*http.Response, []Interim) {
+		got, trace, err := c.do(r)
+		if err != nil {
+			fatalf(t, "%v", err)
+		}
+		// Date changes on every request, so it can never match the golden file.
+		got.Header.Del("Date")
+		if !c.interim {
+			return got, nil
+		}
+		return got, trace.Interim
+	})
+}
+
+type throttledReader struct {
+	r       io.Reader
+	started bool
+	throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.started {
+		time.Sleep(r.interval)
+	}
+	r.started = true
+	if len(p) > r.size {
+		p = p[:r.size]
+	}
+	return r.r.Read(p)
+}
+
+type sentBody struct {
+	io.ReadCloser
+	trace *Trace
+}
+
+func (b *sentBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.trace.BodySent = true
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeClock is a clock to inject into in-process handlers in place of
+// time.Now, so that tests waiting for time-based behavior advance it instead
+// of sleeping.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Since returns the time elapsed since t on the clock.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Eventually calls cond every interval until it returns true, and fails t
+// when it does not within timeout. With a FakeClock, the clock is advanced by
+// interval between the calls instead of sleeping, so the wait is instant.
+// With a nil clock, it sleeps on the wall clock, e.g. against a live server.
+func Eventually(t *testing.T, clock *FakeClock, timeout, interval time.Duration, cond func() bool) {
+	t.Helper()
+
+	for elapsed := time.Duration(0); ; elapsed += interval {
+		if cond() {
+			return
+		}
+		if elapsed >= timeout {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		if clock != nil {
+			clock.Advance(interval)
+		} else {
+			time.Sleep(interval)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Command e2ebluegreen runs an e2e suite against the blue and green
+// deployments in turn and compares the results, to gate traffic switches in
+// deployment pipelines. It fails when a request gets a different status code
+// from green than from blue, when the suite passes against blue but fails
+// against green, or when a route slows down more than allowed.
+//
+//	e2ebluegreen -blue https://blue.example.com -green https://green.example.com -run 'Smoke' ./e2e/...
+//
+// The packages must run their suite with e2e.RunSuite.
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/satorunooshie/e2e"
+)
+
+func main() {
+	blue := flag.String("blue", "", "base URL of the blue deployment")
+	green := flag.String("green", "", "base URL of the green deployment")
+	run := flag.String("run", "", "run only the tests matching the regular expression, e.g. a smoke subset")
+	maxSlowdown := flag.Float64("max-slowdown", 0, "fail when the mean latency of a route on green exceeds blue by more than the ratio, e.g. 0.2 for 20%; 0 disables")
+	flag.Parse()
+	if *blue == "" || *green == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pkgs := flag.Args()
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+
+	dir, err := os.MkdirTemp("", "e2ebluegreen")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blueResults, bluePassed, err := runSuite(*blue, *run, pkgs, filepath.Join(dir, "blue.jsonl"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	greenResults, greenPassed, err := runSuite(*green, *run, pkgs, filepath.Join(dir, "green.jsonl"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ok := report(os.Stdout, blueResults, greenResults, *maxSlowdown)
+	if bluePassed && !greenPassed {
+		fmt.Println("FAIL: the suite passed against blue but failed against green")
+		ok = false
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+// runSuite runs the tests against baseURL and returns the results, reporting
+// whether the tests passed.
+func runSuite(baseURL, run string, pkgs []string, results string) ([]e2e.RequestResult, bool, error) {
+	args := []string{"test", "-count=1", "-p=1"}
+	if run != "" {
+		args = append(args, "-run", run)
+	}
+	args = append(args, pkgs...)
+	args = append(args, "-args", "-results-json="+results)
+
+	cmd := exec.Command("go", args...)
+	cmd.Env = append(os.Environ(), "E2E_BASE_URL="+baseURL)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	fmt.Fprintf(os.Stderr, "running the suite against %s\n", baseURL)
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, false, err
+	}
+
+	f, err := os.Open(results)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	var rs []e2e.RequestResult
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r e2e.RequestResult
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, false, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, cmd.ProcessState.Success(), s.Err()
+}
+
+// requestKey identifies the nth request a test sent to a route.
+type requestKey struct {
+	test, method, route string
+	n                   int
+}
+
+func index(rs []e2e.RequestResult) map[requestKey]e2e.RequestResult {
+	m := make(map[requestKey]e2e.RequestResult, len(rs))
+	seen := make(map[requestKey]int)
+	for _, r := range rs {
+		k := requestKey{test: r.Test, method: r.Method, route: r.Route}
+		k.n = seen[k]
+		seen[k]++
+		m[k] = r
+	}
+	return m
+}
+
+// report writes the status parity and latency delta of green from blue,
+// reporting whether green is acceptable.
+func report(w io.Writer, blue, green []e2e.RequestResult, maxSlowdown float64) bool {
+	ok := true
+	b, g := index(blue), index(green)
+	keys := slices.SortedFunc(maps.Keys(b), func(x, y requestKey) int {
+		return cmp.Or(cmp.Compare(x.test, y.test), cmp.Compare(x.method, y.method), cmp.Compare(x.route, y.route), cmp.Compare(x.n, y.n))
+	})
+
+	fmt.Fprintln(w, "Status parity:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tREQUEST\tBLUE\tGREEN")
+	mismatches := 0
+	for _, k := range keys {
+		gr, found := g[k]
+		if found && gr.Status == b[k].Status {
+			continue
+		}
+		green := "missing"
+		if found {
+			green = fmt.Sprint(gr.Status)
+		}
+		fmt.Fprintf(tw, "%s\t%s %s\t%d\t%s\n", k.test, k.method, k.route, b[k].Status, green)
+		mismatches++
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "%d of %d requests mismatched\n\n", mismatches, len(keys))
+	if mismatches > 0 {
+		ok = false
+	}
+
+	fmt.Fprintln(w, "Latency delta:")
+	bm, gm := meanByRoute(blue), meanByRoute(green)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROUTE\tBLUE\tGREEN\tDELTA")
+	for _, route := range slices.Sorted(maps.Keys(bm)) {
+		gd, found := gm[route]
+		if !found {
+			continue
+		}
+		bd := bm[route]
+		delta := float64(gd-bd) / float64(bd)
+		mark := ""
+		if maxSlowdown > 0 && delta > maxSlowdown {
+			mark = " (too slow)"
+			ok = false
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%+.1f%%%s\n", route, bd, gd, delta*100, mark)
+	}
+	_ = tw.Flush()
+	return ok
+}
+
+func meanByRoute(rs []e2e.RequestResult) map[string]time.Duration {
+	total := make(map[string]time.Duration)
+	count := make(map[string]int)
+	for _, r := range rs {
+		route := r.Method + " " + r.Route
+		total[route] += r.Duration
+		count[route]++
+	}
+	for route := range total {
+		total[route] /= time.Duration(count[route])
+	}
+	return total
+}
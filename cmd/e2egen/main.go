@@ -0,0 +1,25 @@
+// Command e2egen generates table-driven e2e tests from the examples of an
+// OpenAPI 3 document in JSON, with the response examples as the seeds of
+// their golden files.
+//
+//	e2egen -spec openapi.json -dir . -pkg main
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/satorunooshie/e2e"
+)
+
+func main() {
+	spec := flag.String("spec", "openapi.json", "OpenAPI 3 document in JSON")
+	dir := flag.String("dir", ".", "directory of the package to write openapi_test.go and its golden files to")
+	pkg := flag.String("pkg", "main", "name of the package of the tests")
+	flag.Parse()
+
+	if err := e2e.GenerateTests(*spec, *dir, *pkg); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("generated tests of %s in %s\n", *spec, *dir)
+}
@@ -0,0 +1,117 @@
+// Command e2eimpact suggests the e2e tests to run for a change, for fast
+// pre-merge feedback on large services. It reads the files changed, one per
+// line from stdin, and prints a regular expression for go test -run matching
+// the tests exercising the handlers defined in the files, and the tests of
+// the golden files changed. Changes to other Go files select all tests.
+//
+// The routes exercised by each test are read from the results written by a
+// run of the suite with -results-json, which records the source files of the
+// handlers served in-process.
+//
+//	go test ./e2e/... -args -results-json=$PWD/results.jsonl
+//	git diff --name-only main | e2eimpact -results results.jsonl
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/satorunooshie/e2e"
+)
+
+func main() {
+	results := flag.String("results", "", "results of the suite written by -results-json")
+	flag.Parse()
+	if *results == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rs, err := readResults(*results)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var changed []string
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		if f := strings.TrimSpace(s.Text()); f != "" {
+			changed = append(changed, f)
+		}
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	tests, all := impacted(rs, changed)
+	switch {
+	case all:
+		fmt.Println(".")
+	case len(tests) == 0:
+		fmt.Fprintln(os.Stderr, "no e2e tests impacted")
+		fmt.Println("^$")
+	default:
+		for i, test := range tests {
+			tests[i] = regexp.QuoteMeta(test)
+		}
+		fmt.Printf("^(%s)$\n", strings.Join(tests, "|"))
+	}
+}
+
+func readResults(filename string) ([]e2e.RequestResult, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rs []e2e.RequestResult
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r e2e.RequestResult
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, s.Err()
+}
+
+// impacted returns the top-level tests impacted by the changed files, sorted,
+// or reports that all tests are.
+func impacted(rs []e2e.RequestResult, changed []string) ([]string, bool) {
+	tests := make(map[string]struct{})
+	for _, f := range changed {
+		f = path.Clean(f)
+		if _, golden, ok := strings.Cut(f, "testdata/"); ok && strings.HasSuffix(f, ".golden") {
+			// Sidecars such as TestA.links.golden belong to TestA too.
+			name, _, _ := strings.Cut(golden, "/")
+			name, _, _ = strings.Cut(name, ".")
+			tests[name] = struct{}{}
+			continue
+		}
+		if path.Ext(f) != ".go" {
+			continue
+		}
+		found := false
+		for _, r := range rs {
+			if r.Handler != "" && (r.Handler == f || strings.HasSuffix(r.Handler, "/"+f)) {
+				name, _, _ := strings.Cut(r.Test, "/")
+				tests[name] = struct{}{}
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "%s defines no handler exercised by the suite; selecting all tests\n", f)
+			return nil, true
+		}
+	}
+	return slices.Sorted(maps.Keys(tests)), false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/satorunooshie/e2e"
+)
+
+func TestImpacted(t *testing.T) {
+	rs := []e2e.RequestResult{
+		{Test: "TestUserGet/v1_user_200", Route: "GET /v1/user/{id}", Handler: "/src/service/user.go"},
+		{Test: "TestUserPut", Route: "PUT /v1/user/{id}", Handler: "/src/service/user.go"},
+		{Test: "TestHealth", Route: "GET /v1/health", Handler: "/src/service/health.go"},
+		{Test: "TestRemote", Route: "/v1/health"},
+	}
+
+	tests := []struct {
+		description string
+		changed     []string
+		want        []string
+		all         bool
+	}{
+		{
+			description: "handler file",
+			changed:     []string{"service/user.go"},
+			want:        []string{"TestUserGet", "TestUserPut"},
+		},
+		{
+			description: "golden files and sidecars",
+			changed:     []string{"service/testdata/TestHealth.golden", "service/testdata/TestUserGet/v1_user_200.links.golden"},
+			want:        []string{"TestHealth", "TestUserGet"},
+		},
+		{
+			description: "non-Go files",
+			changed:     []string{"README.md", "service/testdata/requests/user.json"},
+			want:        nil,
+		},
+		{
+			description: "Go file without handlers",
+			changed:     []string{"service/health.go", "service/db.go"},
+			all:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			got, all := impacted(rs, tt.changed)
+			if all != tt.all {
+				t.Fatalf("all: %t, want: %t", all, tt.all)
+			}
+			if tt.all {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("impacted tests mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Command e2emock serves a mock of the API from the golden files recorded
+// by an e2e suite.
+//
+//	e2emock -addr :8080 -dir ./testdata
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/satorunooshie/e2e"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dir := flag.String("dir", "testdata", "directory of the golden files indexed by -golden")
+	flag.Parse()
+
+	h, err := e2e.MockHandler(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving mock of %s on %s\n", *dir, *addr)
+	log.Fatal(http.ListenAndServe(*addr, h))
+}
@@ -0,0 +1,24 @@
+// Command e2esite renders the golden files recorded by an e2e suite into a
+// static site browsable without reading the tests, with a page per endpoint
+// listing its responses by status.
+//
+//	e2esite -dir ./testdata -out ./site
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/satorunooshie/e2e"
+)
+
+func main() {
+	dir := flag.String("dir", "testdata", "directory of the golden files indexed by -golden")
+	out := flag.String("out", "site", "directory to write the site to")
+	flag.Parse()
+
+	if err := e2e.ExportSite(*dir, *out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote the site of %s to %s\n", *dir, *out)
+}
@@ -0,0 +1,82 @@
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// goldenClaim is the test which used a golden file in this run.
+type goldenClaim struct {
+	test string
+	t    *testing.T
+}
+
+var goldenClaims struct {
+	mu         sync.Mutex
+	claims     map[string]goldenClaim
+	collisions []string
+}
+
+// claimGolden records that t uses the golden file, returning the failure if
+// another test or an earlier call of t used it in this run, in which case
+// one would silently overwrite or be compared against the response of the
+// other. Reruns of the test by -count are not collisions.
+func claimGolden(t *testing.T, filename string) string {
+	goldenClaims.mu.Lock()
+	defer goldenClaims.mu.Unlock()
+
+	if goldenClaims.claims == nil {
+		goldenClaims.claims = make(map[string]goldenClaim)
+	}
+	other, ok := goldenClaims.claims[filename]
+	switch {
+	case ok && other.t == t:
+		msg := fmt.Sprintf("golden file %s is used twice by %s", filename, t.Name())
+		goldenClaims.collisions = append(goldenClaims.collisions, msg)
+		return msg + "; send the requests in subtests\n"
+	case ok && other.test != t.Name():
+		msg := fmt.Sprintf("golden file %s is used by both %s and %s", filename, other.test, t.Name())
+		goldenClaims.collisions = append(goldenClaims.collisions, msg)
+		return msg + "\n"
+	}
+	goldenClaims.claims[filename] = goldenClaim{test: t.Name(), t: t}
+	return ""
+}
+
+// writeGoldenAudit writes the collisions of golden files in this run,
+// including the golden files whose names differ only in case, which
+// collide on case-insensitive file systems. It reports whether there were
+// any.
+func writeGoldenAudit(w io.Writer) bool {
+	goldenClaims.mu.Lock()
+	defer goldenClaims.mu.Unlock()
+
+	collisions := slices.Clone(goldenClaims.collisions)
+	folded := make(map[string][]string)
+	for _, filename := range slices.Sorted(maps.Keys(goldenClaims.claims)) {
+		key := strings.ToLower(filename)
+		folded[key] = append(folded[key], filename)
+	}
+	for _, key := range slices.Sorted(maps.Keys(folded)) {
+		if files := folded[key]; len(files) > 1 {
+			var tests []string
+			for _, f := range files {
+				tests = append(tests, goldenClaims.claims[f].test)
+			}
+			collisions = append(collisions, fmt.Sprintf("golden files %s differ only in case (%s)", strings.Join(files, ", "), strings.Join(tests, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return false
+	}
+	fmt.Fprintf(w, "e2e: %d golden file collisions:\n", len(collisions))
+	for _, c := range collisions {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+	return true
+}
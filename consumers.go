@@ -0,0 +1,142 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ConsumerManifest lists the fields of the responses a consumer depends on.
+//
+// Fields maps golden file names, as passed to RunTest via t.Name(), to the
+// fields of the JSON body the consumer reads and their types. A field is a
+// dot-separated path where a segment suffixed with "[]" means every element
+// of the array, e.g. "data.users[].name". A type is one of "string",
+// "number", "boolean", "object", "array" and "null", suffixed with "?" when
+// the field may be null.
+//
+//	{
+//	  "consumer": "mobile",
+//	  "fields": {
+//	    "TestUserGetEndpoint/v1_user_1_200_success": {"name": "string", "age": "number?"}
+//	  }
+//	}
+type ConsumerManifest struct {
+	Consumer string                       `json:"consumer"`
+	Fields   map[string]map[string]string `json:"fields"`
+}
+
+// VerifyConsumers verifies that the golden files still contain the fields
+// the consumers in the manifests in dir depend on, with compatible types, so
+// that a change breaking a registered consumer fails the suite.
+func VerifyConsumers(t *testing.T, dir string) {
+	t.Helper()
+
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range manifests {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m ConsumerManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if m.Consumer == "" {
+			m.Consumer = strings.TrimSuffix(filepath.Base(filename), ".json")
+		}
+		for _, name := range slices.Sorted(maps.Keys(m.Fields)) {
+			for _, problem := range verifyConsumerFields(t, name, m.Fields[name]) {
+				t.Errorf("consumer %s: %s: %s", m.Consumer, goldenFileName(name), problem)
+			}
+		}
+	}
+}
+
+func verifyConsumerFields(t *testing.T, name string, fields map[string]string) []string {
+	t.Helper()
+
+	_, _, body, ok := parseDump(readGolden(t, goldenFileName(name)))
+	if !ok {
+		return []string{"malformed golden file"}
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %v", err)}
+	}
+
+	var problems []string
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		want, nullable := strings.CutSuffix(fields[field], "?")
+		values, ok := resolveField(v, strings.Split(field, "."))
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field %q is missing", field))
+			continue
+		}
+		for _, value := range values {
+			if got := jsonType(value); got != want && (got != "null" || !nullable) {
+				problems = append(problems, fmt.Sprintf("field %q is %s, want %s", field, got, fields[field]))
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// resolveField returns the values at path in v, reporting whether every
+// object on the way has the fields.
+func resolveField(v any, path []string) ([]any, bool) {
+	if len(path) == 0 {
+		return []any{v}, true
+	}
+	key, each := strings.CutSuffix(path[0], "[]")
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok = obj[key]
+	if !ok {
+		return nil, false
+	}
+	if !each {
+		return resolveField(v, path[1:])
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	var values []any
+	for _, elem := range arr {
+		sub, ok := resolveField(elem, path[1:])
+		if !ok {
+			return nil, false
+		}
+		values = append(values, sub...)
+	}
+	return values, true
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return "null"
+	}
+}
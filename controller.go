@@ -0,0 +1,101 @@
+package e2e
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ControllerLog records the calls handlers make through
+// http.ResponseController, so that streaming endpoints can be asserted to
+// flush incrementally and to adjust their deadlines. A ControllerLog records
+// every request of the handler it wraps; use one per test.
+type ControllerLog struct {
+	mu             sync.Mutex
+	flushes        []int
+	readDeadlines  []time.Time
+	writeDeadlines []time.Time
+}
+
+// Flushes returns the number of bytes of the body written before each flush
+// since the previous one, in order, e.g. the size of every record of a
+// stream. A streaming endpoint buffering its records flushes them at once,
+// or flushes nothing new.
+func (l *ControllerLog) Flushes() []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]int(nil), l.flushes...)
+}
+
+// ReadDeadlines returns the read deadlines set by handlers, in order.
+func (l *ControllerLog) ReadDeadlines() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.readDeadlines...)
+}
+
+// WriteDeadlines returns the write deadlines set by handlers, in order.
+func (l *ControllerLog) WriteDeadlines() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.writeDeadlines...)
+}
+
+// Handler returns a handler recording the http.ResponseController calls
+// made by h.
+func (l *ControllerLog) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&controllerWriter{ResponseWriter: w, log: l}, r)
+	})
+}
+
+// ObserveController makes the server record the http.ResponseController
+// calls of the router to l.
+func ObserveController(l *ControllerLog) ServerOption {
+	return func(c *serverConfig) {
+		c.middlewares = append(c.middlewares, l.Handler)
+	}
+}
+
+type controllerWriter struct {
+	http.ResponseWriter
+	log *ControllerLog
+	// written is the number of bytes written since the last flush.
+	written int
+}
+
+func (w *controllerWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += n
+	return n, err
+}
+
+func (w *controllerWriter) Flush() {
+	_ = w.FlushError()
+}
+
+func (w *controllerWriter) FlushError() error {
+	w.log.mu.Lock()
+	w.log.flushes = append(w.log.flushes, w.written)
+	w.log.mu.Unlock()
+	w.written = 0
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *controllerWriter) SetReadDeadline(deadline time.Time) error {
+	w.log.mu.Lock()
+	w.log.readDeadlines = append(w.log.readDeadlines, deadline)
+	w.log.mu.Unlock()
+	return http.NewResponseController(w.ResponseWriter).SetReadDeadline(deadline)
+}
+
+func (w *controllerWriter) SetWriteDeadline(deadline time.Time) error {
+	w.log.mu.Lock()
+	w.log.writeDeadlines = append(w.log.writeDeadlines, deadline)
+	w.log.mu.Unlock()
+	return http.NewResponseController(w.ResponseWriter).SetWriteDeadline(deadline)
+}
+
+func (w *controllerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,287 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// UnicodeCorpus is the tricky strings substituted by CheckStrings: emoji,
+// right-to-left text, zero-width and combining characters, null bytes and
+// invalid UTF-8 such as overlong encodings.
+var UnicodeCorpus = []string{
+	"",
+	" ",
+	"\U0001F600", // emoji
+	"\U0001F469\u200d\U0001F469\u200d\U0001F467\u200d\U0001F466", // ZWJ sequence
+	"\U0001F1EF\U0001F1F5",           // flag
+	"\u0645\u0631\u062d\u0628\u0627", // Arabic
+	"\u202eRTL override",
+	"zero\u200bwidth\u200djoiner\ufeff",
+	"e\u0301\u0301\u0301",
+	"Z\u0324\u0354\u0367a\u0308\u0356\u032dl\u036e\u0312g\u0301o",
+	"\u65e5\u672c\u8a9e",
+	"\x00",
+	"null\x00byte",
+	"\t\r\n",
+	" \u3000",
+	"<script>alert(1)</script>",
+	"'\"\\",
+	"\xc0\xaf",     // overlong "/"
+	"\xe0\x80\xaf", // overlong "/"
+	"\xed\xa0\x80", // surrogate half
+	"\xff\xfe",
+	strings.Repeat("\u3042", 1024),
+}
+
+// CheckStrings sends a JSON request to the router for each string field of
+// template, at any depth, substituted with each value of UnicodeCorpus. The
+// handler must never respond 5xx, and when a 2xx JSON response has a field
+// at the same path, it must round-trip valid UTF-8 values unchanged.
+func CheckStrings(t *testing.T, method, endpoint string, template map[string]any, options ...RequestOption) {
+	t.Helper()
+
+	defaultRunner().CheckStrings(t, method, endpoint, template, options...)
+}
+
+// CheckStrings is like CheckStrings but sends the requests with rn.
+func (rn *Runner) CheckStrings(t *testing.T, method, endpoint string, template map[string]any, options ...RequestOption) {
+	t.Helper()
+
+	corpus := make([]json.RawMessage, 0, len(UnicodeCorpus))
+	for _, s := range UnicodeCorpus {
+		corpus = append(corpus, rawJSONString(s))
+	}
+	isString := func(v any) bool {
+		_, ok := v.(string)
+		return ok
+	}
+	rn.runCorpus(t, method, endpoint, template, isString, corpus, options, func(path []any, value json.RawMessage, got *http.Response) {
+		if got.StatusCode >= http.StatusInternalServerError {
+			t.Errorf("%s = %s: HTTP StatusCode: %d\n", formatPath(path), value, got.StatusCode)
+			return
+		}
+		var want string
+		if err := json.Unmarshal(value, &want); err != nil || !utf8.ValidString(want) || got.StatusCode >= http.StatusMultipleChoices {
+			return
+		}
+		var resp any
+		if err := json.Unmarshal(readBody(t, got), &resp); err != nil {
+			return
+		}
+		if got, ok := lookupPath(payload(rn.envelope, resp), path); ok && got != want {
+			t.Errorf("%s = %s: round-trip mismatch: got %q\n", formatPath(path), value, got)
+		}
+	})
+}
+
+// rawJSONString encodes s as a JSON string keeping invalid UTF-8 bytes as
+// they are, unlike encoding/json which replaces them.
+func rawJSONString(s string) json.RawMessage {
+	if utf8.ValidString(s) {
+		b, _ := json.Marshal(s)
+		return b
+	}
+	return json.RawMessage(`"` + s + `"`)
+}
+
+// runCorpus sends a JSON request for every leaf of template matched by match
+// substituted with every value of corpus with rn, and calls check with the
+// response.
+func (rn *Runner) runCorpus(t *testing.T, method, endpoint string, template map[string]any, match func(any) bool, corpus []json.RawMessage, options []RequestOption, check func(path []any, value json.RawMessage, got *http.Response)) {
+	t.Helper()
+
+	const placeholder = "e2e-corpus-placeholder"
+	var send func(*http.Request) (*http.Response, error)
+	// The leaves are matched as decoded from JSON, e.g. numbers as float64
+	// even when template holds ints.
+	for _, path := range leafPaths(deepCopyJSON(t, template), nil, match) {
+		body := deepCopyJSON(t, template)
+		setPath(body, path, placeholder)
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range corpus {
+			b := bytes.Replace(encoded, []byte(strconv.Quote(placeholder)), value, 1)
+			r := NewRequest(method, endpoint, bytes.NewReader(b), options...)
+			if r.Header.Get("Content-Type") == "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			if send == nil {
+				send = rn.sender(t, r)
+			}
+			got, err := send(r)
+			if err != nil {
+				t.Errorf("%s = %s: %v\n", formatPath(path), value, err)
+				continue
+			}
+			check(path, value, got)
+		}
+	}
+}
+
+// leafPaths returns the paths to the leaves of v matched by match. A path
+// consists of map keys and array indexes.
+func leafPaths(v any, parent []any, match func(any) bool) [][]any {
+	var paths [][]any
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			paths = append(paths, leafPaths(v[k], append(slices.Clip(parent), k), match)...)
+		}
+	case []any:
+		for i, sub := range v {
+			paths = append(paths, leafPaths(sub, append(slices.Clip(parent), i), match)...)
+		}
+	default:
+		if match(v) {
+			paths = append(paths, parent)
+		}
+	}
+	return paths
+}
+
+func deepCopyJSON(t *testing.T, v map[string]any) map[string]any {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c map[string]any
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func setPath(v any, path []any, value any) {
+	for i, p := range path {
+		last := i == len(path)-1
+		switch p := p.(type) {
+		case string:
+			m := v.(map[string]any)
+			if last {
+				m[p] = value
+				return
+			}
+			v = m[p]
+		case int:
+			a := v.([]any)
+			if last {
+				a[p] = value
+				return
+			}
+			v = a[p]
+		}
+	}
+}
+
+func lookupPath(v any, path []any) (any, bool) {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[p]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := v.([]any)
+			if !ok || p >= len(a) {
+				return nil, false
+			}
+			v = a[p]
+		}
+	}
+	return v, true
+}
+
+func formatPath(path []any) string {
+	var b strings.Builder
+	for i, p := range path {
+		switch p := p.(type) {
+		case string:
+			if i > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(p)
+		case int:
+			fmt.Fprintf(&b, "#%d", p)
+		}
+	}
+	return b.String()
+}
+
+// NumericCorpus is the boundary values substituted by CheckNumbers: zeros,
+// 32 and 64-bit integer limits and their overflows, float extremes, many
+// decimals and numeric strings.
+var NumericCorpus = []json.RawMessage{
+	json.RawMessage(`0`),
+	json.RawMessage(`-0`),
+	json.RawMessage(`1`),
+	json.RawMessage(`-1`),
+	json.RawMessage(`2147483647`),
+	json.RawMessage(`2147483648`),
+	json.RawMessage(`-2147483649`),
+	json.RawMessage(`9223372036854775807`),
+	json.RawMessage(`9223372036854775808`),
+	json.RawMessage(`-9223372036854775808`),
+	json.RawMessage(`-9223372036854775809`),
+	json.RawMessage(`18446744073709551616`),
+	json.RawMessage(`1.7976931348623157e308`),
+	json.RawMessage(`1e309`),
+	json.RawMessage(`5e-324`),
+	json.RawMessage(`1e-400`),
+	json.RawMessage(`0.1`),
+	json.RawMessage(`3.14159265358979323846264338327950288`),
+	json.RawMessage(`1E2`),
+	json.RawMessage(`"0"`),
+	json.RawMessage(`"-1"`),
+	json.RawMessage(`"9223372036854775808"`),
+	json.RawMessage(`"NaN"`),
+	json.RawMessage(`"Infinity"`),
+	json.RawMessage(`null`),
+}
+
+// CheckNumbers sends a JSON request to the router for each numeric field of
+// template, at any depth, substituted with each value of NumericCorpus. The
+// status class of every response, e.g. 4 for 4xx, must be one of classes,
+// which defaults to 2xx and 4xx.
+func CheckNumbers(t *testing.T, method, endpoint string, template map[string]any, classes []int, options ...RequestOption) {
+	t.Helper()
+
+	defaultRunner().CheckNumbers(t, method, endpoint, template, classes, options...)
+}
+
+// CheckNumbers is like CheckNumbers but sends the requests with rn.
+func (rn *Runner) CheckNumbers(t *testing.T, method, endpoint string, template map[string]any, classes []int, options ...RequestOption) {
+	t.Helper()
+
+	if len(classes) == 0 {
+		classes = []int{2, 4}
+	}
+	isNumber := func(v any) bool {
+		_, ok := v.(float64)
+		return ok
+	}
+	rn.runCorpus(t, method, endpoint, template, isNumber, NumericCorpus, options, func(path []any, value json.RawMessage, got *http.Response) {
+		if !slices.Contains(classes, got.StatusCode/100) {
+			t.Errorf("%s = %s: HTTP StatusCode: %d, want: %vxx\n", formatPath(path), value, got.StatusCode, classes)
+		}
+	})
+}
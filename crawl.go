@@ -0,0 +1,140 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// CrawlOption configures Crawl.
+type CrawlOption func(*crawlConfig)
+
+type crawlConfig struct {
+	maxPages int
+	options  []RequestOption
+}
+
+// MaxPages limits the number of pages Crawl visits. The default is 100.
+func MaxPages(n int) CrawlOption {
+	return func(c *crawlConfig) {
+		c.maxPages = n
+	}
+}
+
+// CrawlRequestOptions applies options to every request sent by Crawl, e.g.
+// for authentication.
+func CrawlRequestOptions(options ...RequestOption) CrawlOption {
+	return func(c *crawlConfig) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// CrawlResult is a page visited by Crawl.
+type CrawlResult struct {
+	URL        string
+	StatusCode int
+	// Route is the route pattern which served the page, if known.
+	Route string
+	// From is the page linking to the page, or empty for seeds.
+	From string
+}
+
+var hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"']+)["']`)
+
+// Crawl sends GET requests to the router starting from seeds and following
+// the links within the service found in the responses: the hypermedia links
+// of JSON responses, href attributes of HTML responses and Location headers.
+// It fails on 5xx responses and links responding 404 or 410, and logs the
+// visited pages and the number of route patterns they cover, as a cheap smoke
+// test of the whole surface of the service.
+func Crawl(t *testing.T, seeds []string, opts ...CrawlOption) []CrawlResult {
+	t.Helper()
+
+	return defaultRunner().Crawl(t, seeds, opts...)
+}
+
+// Crawl is like Crawl but sends the requests with rn. Routes are only known
+// for pages served in process.
+func (rn *Runner) Crawl(t *testing.T, seeds []string, opts ...CrawlOption) []CrawlResult {
+	t.Helper()
+
+	cfg := crawlConfig{maxPages: 100}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	type page struct{ url, from string }
+	queue := make([]page, 0, len(seeds))
+	seen := make(map[string]bool)
+	for _, s := range seeds {
+		queue = append(queue, page{url: s})
+		seen[s] = true
+	}
+
+	var results []CrawlResult
+	var send func(*http.Request) (*http.Response, error)
+	routes := make(map[string]bool)
+	for len(queue) > 0 && len(results) < cfg.maxPages {
+		p := queue[0]
+		queue = queue[1:]
+
+		r := NewRequest(http.MethodGet, p.url, nil, cfg.options...)
+		if send == nil {
+			send = rn.sender(t, r)
+		}
+		resp, err := send(r)
+		if err != nil {
+			t.Errorf("GET %s (linked from %q): %v\n", p.url, p.from, err)
+			continue
+		}
+
+		results = append(results, CrawlResult{URL: p.url, StatusCode: resp.StatusCode, Route: r.Pattern, From: p.from})
+		if r.Pattern != "" {
+			routes[r.Pattern] = true
+		}
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError:
+			t.Errorf("GET %s (linked from %q): HTTP StatusCode: %d\n", p.url, p.from, resp.StatusCode)
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			t.Errorf("GET %s (linked from %q): broken link: HTTP StatusCode: %d\n", p.url, p.from, resp.StatusCode)
+		}
+
+		for _, href := range discoverLinks(resp, readBody(t, resp)) {
+			path, ok := servicePath(resp, href)
+			if !ok || seen[path] {
+				continue
+			}
+			seen[path] = true
+			queue = append(queue, page{url: path, from: p.url})
+		}
+	}
+
+	for _, r := range results {
+		t.Logf("%d %s\n", r.StatusCode, r.URL)
+	}
+	t.Logf("crawled %d pages covering %d route patterns\n", len(results), len(routes))
+	return results
+}
+
+func discoverLinks(resp *http.Response, body []byte) []string {
+	var hrefs []string
+	if loc := resp.Header.Get("Location"); loc != "" {
+		hrefs = append(hrefs, loc)
+	}
+	switch ct := resp.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, "application/json"), strings.HasSuffix(strings.Split(ct, ";")[0], "+json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err == nil {
+			for _, l := range ExtractLinks(v) {
+				hrefs = append(hrefs, l.Href)
+			}
+		}
+	case strings.HasPrefix(ct, "text/html"):
+		for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+			hrefs = append(hrefs, string(m[1]))
+		}
+	}
+	return hrefs
+}
@@ -0,0 +1,78 @@
+package e2e
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+)
+
+// DependencyKind is how a dependency of the router is implemented in a run.
+type DependencyKind string
+
+const (
+	// RealDependency is the production implementation, e.g. a managed
+	// database.
+	RealDependency DependencyKind = "real"
+	// ContainerDependency is the real software run in a container for the
+	// suite.
+	ContainerDependency DependencyKind = "container"
+	// MockDependency is a fake or mock.
+	MockDependency DependencyKind = "mock"
+)
+
+type dependency struct {
+	name           string
+	kind           DependencyKind
+	implementation string
+}
+
+var dependencies struct {
+	mu   sync.Mutex
+	deps []dependency
+}
+
+// ReportDependency records the implementation of the dependency name of the
+// router in this run, e.g. from the router constructor. RunSuite lists the
+// dependencies at suite end, showing at a glance how end-to-end the run was:
+//
+//	store := newMemoryStore()
+//	e2e.ReportDependency("users", e2e.MockDependency, fmt.Sprintf("%T", store))
+func ReportDependency(name string, kind DependencyKind, implementation string) {
+	dependencies.mu.Lock()
+	defer dependencies.mu.Unlock()
+
+	d := dependency{name: name, kind: kind, implementation: implementation}
+	if !slices.Contains(dependencies.deps, d) {
+		dependencies.deps = append(dependencies.deps, d)
+	}
+}
+
+func writeDependencyReport(w io.Writer) {
+	dependencies.mu.Lock()
+	deps := slices.Clone(dependencies.deps)
+	dependencies.mu.Unlock()
+
+	if len(deps) == 0 {
+		return
+	}
+	slices.SortFunc(deps, func(a, b dependency) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.kind, b.kind), cmp.Compare(a.implementation, b.implementation))
+	})
+	reals := 0
+	for _, d := range deps {
+		if d.kind == RealDependency {
+			reals++
+		}
+	}
+
+	fmt.Fprintf(w, "Dependencies (%d of %d real):\n", reals, len(deps))
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tKIND\tIMPLEMENTATION")
+	for _, d := range deps {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", d.name, d.kind, d.implementation)
+	}
+	_ = tw.Flush()
+}
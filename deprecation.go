@@ -0,0 +1,142 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// defaultDeprecationWarning is how long before the sunset RunTest warns by
+// default.
+const defaultDeprecationWarning = 30 * 24 * time.Hour
+
+// Deprecation marks an endpoint as deprecated until its sunset.
+type Deprecation struct {
+	// Pattern matches the requests to the endpoint in the syntax of
+	// http.ServeMux, e.g. "GET /v1/user/{id}".
+	Pattern string
+	// Sunset is when the endpoint must be removed.
+	Sunset time.Time
+	// Warning is how long before Sunset RunTest starts warning. The default
+	// is 30 days.
+	Warning time.Duration
+	// Link is the documentation of the deprecation, if any.
+	Link string
+}
+
+var deprecations struct {
+	mu  sync.Mutex
+	mux *http.ServeMux
+	m   map[string]Deprecation
+}
+
+// RegisterDeprecation marks an endpoint as deprecated. RunTest warns about
+// requests to the endpoint as the sunset approaches, and fails when the
+// endpoint still responds 2xx after the sunset, enforcing actual removal.
+func RegisterDeprecation(d Deprecation) {
+	deprecations.mu.Lock()
+	defer deprecations.mu.Unlock()
+
+	if deprecations.mux == nil {
+		deprecations.mux = http.NewServeMux()
+		deprecations.m = make(map[string]Deprecation)
+	}
+	if d.Warning == 0 {
+		d.Warning = defaultDeprecationWarning
+	}
+	deprecations.mux.Handle(d.Pattern, http.NotFoundHandler())
+	deprecations.m[d.Pattern] = d
+}
+
+// deprecationOf returns the deprecation of the endpoint r is sent to.
+func deprecationOf(r *http.Request) (Deprecation, bool) {
+	deprecations.mu.Lock()
+	defer deprecations.mu.Unlock()
+
+	if deprecations.mux == nil {
+		return Deprecation{}, false
+	}
+	_, pattern := deprecations.mux.Handler(r)
+	d, ok := deprecations.m[pattern]
+	return d, ok
+}
+
+// checkDeprecation returns the failure of a request to a sunset endpoint,
+// and the warning about an approaching sunset.
+func checkDeprecation(r *http.Request, status int, now time.Time) (failure, warning string) {
+	d, ok := deprecationOf(r)
+	if !ok {
+		return "", ""
+	}
+	switch {
+	case !now.Before(d.Sunset):
+		if status >= 200 && status < 300 {
+			return fmt.Sprintf("%s was sunset on %s but still responds %d; remove the endpoint\n", d.Pattern, d.Sunset.Format(time.DateOnly), status), ""
+		}
+	case now.After(d.Sunset.Add(-d.Warning)):
+		return "", fmt.Sprintf("warning: %s is deprecated and will be sunset on %s\n", d.Pattern, d.Sunset.Format(time.DateOnly))
+	}
+	return "", ""
+}
+
+// DeprecationHeaders is a ResponseFilter asserting that the responses of
+// endpoints registered with RegisterDeprecation signal it: the Deprecation
+// header of RFC 9745, e.g. "@1688169599", the Sunset header of RFC 8594 at
+// the registered sunset, and a Link with rel="deprecation" to the registered
+// documentation, if any. Responses of other endpoints must not have
+// Deprecation or Sunset headers, so that the suite config and the API agree
+// on the lifecycle. Error responses are not checked, since they often come
+// from middleware unaware of the endpoint.
+func DeprecationHeaders(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if r.Request == nil || r.StatusCode >= http.StatusBadRequest {
+		return
+	}
+	d, ok := deprecationOf(r.Request)
+	if !ok {
+		for _, key := range []string{"Deprecation", "Sunset"} {
+			if v := r.Header.Get(key); v != "" {
+				errorf(t, r, "DeprecationHeaders: %s %s has %s: %s but is not registered with RegisterDeprecation\n", r.Request.Method, r.Request.URL.Path, key, v)
+			}
+		}
+		return
+	}
+
+	switch v := r.Header.Get("Deprecation"); {
+	case v == "":
+		errorf(t, r, "DeprecationHeaders: %s is deprecated but has no Deprecation header\n", d.Pattern)
+	case !deprecationDate.MatchString(v):
+		errorf(t, r, "DeprecationHeaders: Deprecation: %s is not a date like @1688169599\n", v)
+	}
+
+	if v := r.Header.Get("Sunset"); v == "" {
+		errorf(t, r, "DeprecationHeaders: %s is deprecated but has no Sunset header\n", d.Pattern)
+	} else if sunset, err := http.ParseTime(v); err != nil {
+		errorf(t, r, "DeprecationHeaders: Sunset: %s is not an HTTP date\n", v)
+	} else if !sunset.Equal(d.Sunset.Truncate(time.Second)) {
+		errorf(t, r, "DeprecationHeaders: Sunset: %s, want: %s\n", v, d.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if d.Link == "" {
+		return
+	}
+	links, err := parseLinkHeader(r.Header.Values("Link"))
+	if err != nil {
+		errorf(t, r, "DeprecationHeaders: %v\n", err)
+		return
+	}
+	for _, l := range links {
+		if l.Rel == "deprecation" && l.URL == d.Link {
+			return
+		}
+	}
+	errorf(t, r, "DeprecationHeaders: Link has no rel=\"deprecation\" to %s\n", d.Link)
+}
+
+// deprecationDate matches the Date structured field of the Deprecation
+// header.
+var deprecationDate = regexp.MustCompile(`^@-?\d+$`)
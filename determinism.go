@@ -0,0 +1,73 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+)
+
+// CheckDeterministic sends r to the router n times and fails with the
+// differences between the responses after the filters, pinpointing sources
+// of nondeterminism, such as map iteration order or timestamps, before they
+// make golden files flaky. The filters are applied to every response, so
+// that normalized fields are not reported.
+func CheckDeterministic(t *testing.T, r *http.Request, n int, filters ...ResponseFilter) {
+	t.Helper()
+
+	defaultRunner().CheckDeterministic(t, r, n, filters...)
+}
+
+// CheckDeterministic is like CheckDeterministic but sends r with rn. All
+// requests are served by the same router or server.
+func (rn *Runner) CheckDeterministic(t *testing.T, r *http.Request, n int, filters ...ResponseFilter) {
+	t.Helper()
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send := rn.sender(t, r)
+	var first *http.Response
+	var firstDump []byte
+	for i := range n {
+		req := r.Clone(r.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		got, err := send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Request = withRunner(req, rn)
+		decodeContentEncoding(t, got)
+		normalizeEnvelope(t, rn.envelope, got)
+		for _, f := range filters {
+			f(t, got)
+		}
+
+		dump, err := httputil.DumpResponse(got, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first, firstDump = got, dump
+			continue
+		}
+		if bytes.Equal(dump, firstDump) {
+			continue
+		}
+		name := fmt.Sprintf("response %d", i+1)
+		if diffs := responseDiff(t, first, got, name); len(diffs) > 0 {
+			t.Errorf("%s %s: %s differs from response 1:\n\t%s\n", r.Method, r.URL, name, strings.Join(diffs, "\n\t"))
+		} else {
+			t.Errorf("%s %s: %s differs from response 1 in formatting, such as the order of fields\n", r.Method, r.URL, name)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package e2e
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// DigestOption configures BinaryDigest.
+type DigestOption func(*digestConfig)
+
+type digestConfig struct {
+	sidecar bool
+}
+
+// DigestSidecar makes BinaryDigest store the raw bytes of the body next to
+// the golden file with -golden, e.g. testdata/TestLogo.png, so that the
+// binary approved with the digest can be inspected.
+func DigestSidecar() DigestOption {
+	return func(c *digestConfig) {
+		c.sidecar = true
+	}
+}
+
+// BinaryDigest returns a ResponseFilter replacing binary bodies, e.g. of
+// application/octet-stream and images, with their length and SHA-256 digest,
+// so that golden files stay text and their diffs readable. Bodies are
+// binary when their media type is not textual or when they are not valid
+// UTF-8. Textual bodies are left as is.
+func BinaryDigest(opts ...DigestOption) ResponseFilter {
+	var cfg digestConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if len(body) == 0 || textual(mediaType) && utf8.Valid(body) {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			return
+		}
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "binary body: %d bytes\nsha256: %x\n", len(body), sha256.Sum256(body))
+		if cfg.sidecar {
+			filename := filepath.Join("testdata", t.Name()+sidecarExt(mediaType))
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filename, body, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fmt.Fprintf(&buf, "sidecar: %s\n", filepath.ToSlash(filename))
+		}
+		r.Body = io.NopCloser(&buf)
+	}
+}
+
+// textual reports whether bodies of the media type are text.
+func textual(mediaType string) bool {
+	switch {
+	case mediaType == "":
+		// Unlabeled bodies are told apart by their encoding.
+		return true
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"),
+		isNDJSON(mediaType):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/graphql", "application/yaml":
+		return true
+	}
+	return false
+}
+
+// sidecarExt returns the file extension of the media type, or ".bin".
+func sidecarExt(mediaType string) string {
+	switch mediaType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	case "application/zip":
+		return ".zip"
+	}
+	return ".bin"
+}
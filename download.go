@@ -0,0 +1,111 @@
+package e2e
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// DownloadOption configures Download.
+type DownloadOption func(*downloadConfig)
+
+type downloadConfig struct {
+	filename  string
+	mediaType string
+	sha256    string
+	path      *string
+}
+
+// AttachmentName makes Download assert the file name in Content-Disposition,
+// decoded from filename* when it is given.
+func AttachmentName(name string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.filename = name
+	}
+}
+
+// DownloadType makes Download assert the media type of Content-Type, without
+// its parameters, e.g. "text/csv".
+func DownloadType(mediaType string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.mediaType = mediaType
+	}
+}
+
+// SHA256 makes Download assert the SHA-256 checksum of the body in hex.
+func SHA256(checksum string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.sha256 = strings.ToLower(checksum)
+	}
+}
+
+// SaveDownload makes Download save the body to a file in the temporary
+// directory of the test, named after the attachment, and set *path to it, so
+// that the test can validate the file further, e.g. by opening an archive.
+func SaveDownload(path *string) DownloadOption {
+	return func(c *downloadConfig) {
+		c.path = path
+	}
+}
+
+// Download returns a ResponseFilter asserting that the response is a file
+// download, i.e. has a Content-Disposition of attachment, and what the
+// options assert about it. The body is left as is for the golden file;
+// combine it with BinaryDigest for binary files.
+func Download(opts ...DownloadOption) ResponseFilter {
+	var cfg downloadConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		disposition, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition"))
+		switch {
+		case err != nil:
+			errorf(t, r, "Download: malformed Content-Disposition %q: %v\n", r.Header.Get("Content-Disposition"), err)
+		case disposition != "attachment":
+			errorf(t, r, "Download: Content-Disposition: %q, want: %q\n", disposition, "attachment")
+		}
+		if cfg.filename != "" && params["filename"] != cfg.filename {
+			errorf(t, r, "Download: filename: %q, want: %q\n", params["filename"], cfg.filename)
+		}
+		if cfg.mediaType != "" {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != cfg.mediaType {
+				errorf(t, r, "Download: Content-Type: %q, want: %q\n", mediaType, cfg.mediaType)
+			}
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if cfg.sha256 != "" {
+			sum := sha256.Sum256(body)
+			if got := hex.EncodeToString(sum[:]); got != cfg.sha256 {
+				errorf(t, r, "Download: SHA-256: %s, want: %s\n", got, cfg.sha256)
+			}
+		}
+		if cfg.path != nil {
+			// The name is from the server, so only its base is used.
+			name := filepath.Base(filepath.FromSlash(params["filename"]))
+			if name == "." || name == string(filepath.Separator) {
+				name = "download"
+			}
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, body, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			*cfg.path = path
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseDump parses a response dumped to a golden file into the status code,
+// headers and body of the final response, skipping informational responses.
+func parseDump(data []byte) (code int, header http.Header, body []byte, ok bool) {
+	for {
+		head, rest, found := bytes.Cut(data, []byte("\r\n\r\n"))
+		if !found {
+			return 0, nil, nil, false
+		}
+		statusLine, fields, _ := bytes.Cut(head, []byte("\r\n"))
+		_, status, _ := strings.Cut(string(statusLine), " ")
+		codeStr, _, _ := strings.Cut(status, " ")
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return 0, nil, nil, false
+		}
+		if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols && len(rest) > 0 {
+			data = rest
+			continue
+		}
+
+		h := make(http.Header)
+		if len(fields) > 0 {
+			mh, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(fields, "\r\n\r\n"...)))).ReadMIMEHeader()
+			if err != nil {
+				return 0, nil, nil, false
+			}
+			h = http.Header(mh)
+		}
+		return code, h, rest, true
+	}
+}
+
+type preserveOrderKey struct{}
+
+// PreserveHeaderOrder keeps the order of the values of the header keys in
+// the golden file, or of all keys when none are given, for headers whose
+// order is significant. The values of repeated headers are otherwise sorted,
+// since their order varies across middleware.
+func PreserveHeaderOrder(keys ...string) RequestOption {
+	keys = slices.Clone(keys)
+	for i, k := range keys {
+		keys[i] = http.CanonicalHeaderKey(k)
+	}
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), preserveOrderKey{}, keys))
+	}
+}
+
+// canonicalizeHeader sorts the values of the repeated headers of h, except
+// the ones preserved by PreserveHeaderOrder for r.
+func canonicalizeHeader(r *http.Request, h http.Header) {
+	var preserved []string
+	if r != nil {
+		keys, ok := r.Context().Value(preserveOrderKey{}).([]string)
+		if ok && len(keys) == 0 {
+			return
+		}
+		preserved = keys
+	}
+	for k, v := range h {
+		if len(v) > 1 && !slices.Contains(preserved, k) {
+			slices.Sort(v)
+		}
+	}
+}
+
+// sortDumpHeaderValues sorts the values of the repeated headers of the
+// responses dumped in data, as canonicalizeHeader does. Dumps write the
+// header keys sorted, so the values of a key are adjacent lines.
+func sortDumpHeaderValues(data []byte) []byte {
+	var out []byte
+	for {
+		head, rest, found := bytes.Cut(data, []byte("\r\n\r\n"))
+		if !found || !bytes.HasPrefix(head, []byte("HTTP/")) {
+			return append(out, data...)
+		}
+		lines := bytes.Split(head, []byte("\r\n"))
+		fields := lines[1:]
+		for i := 0; i < len(fields); {
+			key, _, _ := bytes.Cut(fields[i], []byte(":"))
+			prefix := append(slices.Clip(key), ':')
+			j := i + 1
+			for j < len(fields) && bytes.HasPrefix(fields[j], prefix) {
+				j++
+			}
+			slices.SortFunc(fields[i:j], bytes.Compare)
+			i = j
+		}
+		out = append(out, bytes.Join(lines, []byte("\r\n"))...)
+		out = append(out, "\r\n\r\n"...)
+
+		statusLine := lines[0]
+		_, status, _ := bytes.Cut(statusLine, []byte(" "))
+		code, _ := strconv.Atoi(string(bytes.SplitN(status, []byte(" "), 2)[0]))
+		if code < 100 || code >= 200 || code == http.StatusSwitchingProtocols {
+			return append(out, rest...)
+		}
+		data = rest
+	}
+}
@@ -2,17 +2,28 @@ package e2e
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -20,31 +31,100 @@ import (
 var (
 	router          http.Handler
 	dumpRawResponse = flag.Bool("dump", false, "dump raw response")
+	dumpMaxBytes    = flag.Int("dump-max", 0, "limit the body dumped by -dump to the bytes, keeping its beginning and end; 0 disables")
 	updateGolden    = flag.Bool("golden", false, "update golden files")
+	writeReceived   = flag.Bool("received", false, "write responses mismatching golden files to .received files")
+	strictMode      = flag.Bool("strict", false, "fail on filters that had no effect")
 )
 
 // RegisterRouter registers router for RunTest.
 func RegisterRouter(rt http.Handler) {
 	router = rt
+
+	factory.mu.Lock()
+	factory.f = nil
+	factory.mu.Unlock()
 }
 
 // ResponseFilter is a function to modify HTTP response.
 type ResponseFilter func(t *testing.T, r *http.Response)
 
+var registeredFilters []ResponseFilter
+
+// RegisterFilter makes RunTest apply the filters to every response before
+// the filters of the test, e.g. for checks which are part of the contract
+// of every endpoint.
+func RegisterFilter(filters ...ResponseFilter) {
+	registeredFilters = append(registeredFilters, filters...)
+}
+
+// WithFilters is like RegisterFilter for the Runner.
+func WithFilters(filters ...ResponseFilter) RunnerOption {
+	return func(rn *Runner) {
+		rn.filters = append(rn.filters, filters...)
+	}
+}
+
 // RunTest sends an HTTP request to router, then checks the status code and
 // compare the response with the golden file. When `updateGolden` is true,
-// update the golden file instead of comparison.
+// update the golden file instead of comparison. With -base-url or
+// $E2E_BASE_URL, the request is sent to the live server at the URL instead.
 func RunTest(t *testing.T, r *http.Request, want int, filters ...ResponseFilter) {
 	t.Helper()
 
+	defaultRunner().RunTest(t, r, want, filters...)
+}
+
+func runTest(t *testing.T, rn *Runner, r *http.Request, want int, filters []ResponseFilter, served func(*http.Response), serve func(*http.Request) (*http.Response, []Interim)) {
+	t.Helper()
+
 	t.Logf(">>> %s %s\n", r.Method, r.URL)
+	filters = append(append(slices.Clip(rn.filters), rn.familyFilters(r)...), filters...)
+	if len(rn.namespaceFields) > 0 {
+		WithNamespace(rn.namespaceFields...)(r)
+		filters = append([]ResponseFilter{maskNamespace}, filters...)
+	}
+
+	mistakes, err := validateRequest(r)
+	if err != nil {
+		fatalf(t, "%v", err)
+	}
+	if len(mistakes) > 0 {
+		fatalf(t, "invalid request %s %s:\n\t%s", r.Method, r.URL, strings.Join(mistakes, "\n\t"))
+	}
 
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, r)
+	var sr *http.Request
+	if rn.shadow != nil {
+		sr = shadowRequest(t, r)
+	}
 
-	got := w.Result()
+	start := time.Now()
+	got, interim := serve(r)
+	if got.Request == nil {
+		got.Request = r
+	}
+	got.Request = withRunner(got.Request, rn)
+	if served != nil {
+		served(got)
+	}
+	meta := metaOf(got.Request)
+	meta.collect = true
+	storeCookies(r, got)
+	recordRequest(t, r, got.StatusCode, time.Since(start))
+
+	// Mismatches are reported at the end, so that quarantined tests can
+	// report them separately.
+	var failures []string
 	if got.StatusCode != want {
-		t.Errorf("HTTP StatusCode: %d, want: %d\n", got.StatusCode, want)
+		failures = append(failures, fmt.Sprintf("HTTP StatusCode: %d, want: %d\n", got.StatusCode, want))
+	}
+	failures = append(failures, checkEmptyResponse(t, got)...)
+	failure, warning := checkDeprecation(r, got.StatusCode, time.Now())
+	if failure != "" {
+		failures = append(failures, failure)
+	}
+	if warning != "" {
+		t.Log(warning)
 	}
 
 	if *dumpRawResponse {
@@ -55,7 +135,7 @@ func RunTest(t *testing.T, r *http.Request, want int, filters ...ResponseFilter)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if strings.HasPrefix(got.Header.Get("Content-Type"), "application/json") {
+		if strings.HasPrefix(got.Header.Get("Content-Type"), "application/json") && got.Header.Get("Content-Encoding") == "" {
 			switch got.StatusCode {
 			case http.StatusOK, http.StatusCreated:
 				body = indentJSON(t, body)
@@ -67,28 +147,177 @@ func RunTest(t *testing.T, r *http.Request, want int, filters ...ResponseFilter)
 			t.Fatal(err)
 		}
 
-		t.Logf("Raw response:\n%s%s\n", dump, body)
+		t.Logf("Raw response:\n%s%s\n", dump, truncate(body, *dumpMaxBytes))
 	}
 
+	// The shadow is filtered first, so that filters capturing the response
+	// end up with the actual one.
+	var shadowed *http.Response
+	if sr != nil {
+		shadowed = serveShadow(t, rn, sr, filters)
+	}
+	decodeContentEncoding(t, got)
+	normalizeEnvelope(t, rn.envelope, got)
 	for _, f := range filters {
 		f(t, got)
 	}
+	failures = append(failures, meta.failures...)
+	if shadowed != nil {
+		if diffs := responseDiff(t, got, shadowed, "shadow"); len(diffs) > 0 {
+			failures = append(failures, fmt.Sprintf("shadow response differs:\n\t%s\n", strings.Join(diffs, "\n\t")))
+		}
+	}
+	normalizeContentLength(r, got)
 
+	normalizeVolatileHeaders(r, got.Header)
+	canonicalizeHeader(r, got.Header)
+	for _, i := range interim {
+		normalizeVolatileHeaders(r, i.Header)
+		canonicalizeHeader(r, i.Header)
+	}
 	dump, err := httputil.DumpResponse(got, true)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(interim) > 0 {
+		dump = append(dumpInterim(t, got.Proto, interim), dump...)
+	}
 
-	if *updateGolden {
-		writeGolden(t, dump)
-	} else {
-		golden := readGolden(t)
-		if diff := cmp.Diff(golden, dump); diff != "" {
-			t.Errorf("HTTP Response mismatch (-want +got):\n%s", diff)
-		}
+	name := goldenNameOf(t, rn, r, got.StatusCode)
+	if _, xfailed := expectedFailure(t); *updateGolden && !xfailed {
+		recordMockEntry(name, r)
 	}
+	if mismatch := compareGoldenNamed(t, name, dump); mismatch != "" {
+		failures = append(failures, "HTTP Response "+mismatch)
+	}
+
+	t.Logf("<<< %s\n", goldenFileName(name))
+	reportFailures(t, failures)
+}
+
+// CompareGolden compares data with the golden file of t, or updates it with
+// -golden, like RunTest does with the response. It lets subsystems testing
+// other protocols share the golden workflow, including -received, XFail and
+// quarantine.
+func CompareGolden(t *testing.T, data []byte) {
+	t.Helper()
 
+	var failures []string
+	if mismatch := compareGolden(t, data); mismatch != "" {
+		failures = append(failures, "Golden "+mismatch)
+	}
 	t.Logf("<<< %s\n", goldenFileName(t.Name()))
+	reportFailures(t, failures)
+}
+
+// compareGolden compares dump with the golden file of t, or updates it with
+// -golden, returning the mismatch if any.
+func compareGolden(t *testing.T, dump []byte) string {
+	t.Helper()
+
+	return compareGoldenNamed(t, t.Name(), dump)
+}
+
+// compareGoldenNamed is like compareGolden for the golden file of name.
+func compareGoldenNamed(t *testing.T, name string, dump []byte) string {
+	t.Helper()
+
+	filename := goldenFileName(name)
+	if collision := claimGolden(t, filename); collision != "" {
+		return collision
+	}
+	x, xfailed := expectedFailure(t)
+	if *updateGolden && xfailed {
+		// The response of a known bug must not become the golden file, so it
+		// is compared instead.
+		t.Logf("not updating %s of expected failure (%s)\n", filename, x.reason)
+	}
+	if *updateGolden && !xfailed {
+		// Golden files with placeholders are written by hand, so they are
+		// kept as long as they match.
+		if _, err := os.Stat(filename); err == nil {
+			if golden := readGolden(t, filename); hasPlaceholders(golden) {
+				if ok, _ := matchPlaceholders(golden, dump); ok {
+					return ""
+				}
+			}
+		}
+		recordGoldenChange(t, filename, dump)
+		writeGolden(t, filename, dump)
+		return ""
+	}
+
+	if _, err := os.Stat(filename); *writeReceived && errors.Is(err, fs.ErrNotExist) {
+		updateReceived(t, name, dump, false)
+	}
+	golden := readGolden(t, filename)
+	// cmp.Diff is slow on large bodies, so only diff when they differ.
+	matched, err := equalGolden(golden, dump)
+	if err != nil {
+		return fmt.Sprintf("golden %s: %v\n", filename, err)
+	}
+	if *writeReceived {
+		updateReceived(t, name, dump, matched)
+	}
+	if matched {
+		return ""
+	}
+	return fmt.Sprintf("mismatch (-want +got):\n%s", cmp.Diff(golden, dump))
+}
+
+func reportFailures(t *testing.T, failures []string) {
+	t.Helper()
+
+	if x, ok := expectedFailure(t); ok {
+		x.record(t, failures)
+		return
+	}
+	if reason, ok := quarantined(t); ok {
+		if len(failures) > 0 {
+			t.Skipf("quarantined (%s):\n%s", reason, strings.Join(failures, ""))
+		}
+		return
+	}
+	for _, f := range failures {
+		t.Error(f)
+	}
+}
+
+// fatalf is like t.Fatalf but reports the failure with reportFailures, so
+// that XFail and quarantine apply to it too.
+func fatalf(t *testing.T, format string, args ...any) {
+	t.Helper()
+
+	reportFailures(t, []string{fmt.Sprintf(format, args...)})
+	if _, ok := expectedFailure(t); ok {
+		t.SkipNow()
+	}
+	t.FailNow()
+}
+
+func dumpInterim(t *testing.T, proto string, interim []Interim) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, r := range interim {
+		fmt.Fprintf(&buf, "%s %d %s\r\n", proto, r.StatusCode, http.StatusText(r.StatusCode))
+		if err := r.Header.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// truncate returns body limited to max bytes by omitting its middle with a
+// marker counting the omitted bytes.
+func truncate(body []byte, max int) []byte {
+	if max <= 0 || len(body) <= max {
+		return body
+	}
+	head, tail := body[:max/2], body[len(body)-(max-max/2):]
+	marker := fmt.Sprintf("\n... [%d bytes omitted] ...\n", len(body)-max)
+	return slices.Concat(head, []byte(marker), tail)
 }
 
 // This is a modified version of httputil.drainBody for this test.
@@ -107,10 +336,25 @@ func drainBody(t *testing.T, b io.ReadCloser) (dump, orig io.ReadCloser) {
 	return io.NopCloser(&buf), io.NopCloser(bytes.NewReader(buf.Bytes()))
 }
 
+// normalizeContentLength removes Content-Length from the response to r, so
+// that golden files are the same whether the server framed the body with
+// Content-Length, as real servers do for small bodies, or not, as responses
+// recorded in process, and whether filters rewrote the body or not. HEAD
+// requests and responses without content keep it, as it describes the
+// representation there.
+func normalizeContentLength(r *http.Request, got *http.Response) {
+	if r.Method == http.MethodHead || bodyless(got.StatusCode) {
+		return
+	}
+	got.Header.Del("Content-Length")
+	got.ContentLength = -1
+}
+
 func indentJSON(t *testing.T, body []byte) []byte {
 	t.Helper()
 
-	var tmp map[string]any
+	// Top-level arrays and scalars are valid JSON texts as well as objects.
+	var tmp any
 	if err := json.Unmarshal(body, &tmp); err != nil {
 		t.Fatal(err)
 	}
@@ -125,29 +369,125 @@ func goldenFileName(name string) string {
 	return filepath.Join("testdata", name+".golden")
 }
 
-func writeGolden(t *testing.T, data []byte) {
+func writeGolden(t *testing.T, filename string, data []byte) {
 	t.Helper()
 
-	filename := goldenFileName(t.Name())
 	if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile(filename, data, 0o600); err != nil {
+	if err := os.WriteFile(filename, encodeGolden(data), 0o600); err != nil {
 		t.Fatal(err)
 	}
 }
 
-func readGolden(t *testing.T) []byte {
+// goldenCache holds the golden files read so far keyed by file name, so that
+// suites reading the same golden files repeatedly, e.g. with -count, only
+// read a file again when it changed.
+var goldenCache sync.Map // map[string]cachedGolden
+
+type cachedGolden struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// compareSidecar compares data with the golden file next to the golden file
+// of t with suffix ext, or updates it with -golden.
+func compareSidecar(t *testing.T, ext string, data []byte) {
 	t.Helper()
 
-	data, err := os.ReadFile(goldenFileName(t.Name()))
+	filename := filepath.Join("testdata", t.Name()+ext)
+	if *updateGolden {
+		writeGolden(t, filename, data)
+		return
+	}
+	if golden := readGolden(t, filename); !bytes.Equal(golden, data) {
+		t.Errorf("%s mismatch (-want +got):\n%s", filename, cmp.Diff(golden, data))
+	}
+}
+
+func receivedFileName(name string) string {
+	return filepath.Join("testdata", name+".received")
+}
+
+// updateReceived writes data to the received file next to the golden file
+// for approval, or removes a stale one when the response matched.
+func updateReceived(t *testing.T, name string, data []byte, matched bool) {
+	t.Helper()
+
+	filename := receivedFileName(name)
+	if matched {
+		if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal(err)
+		}
+		return
+	}
+	// The golden directory of a new test does not exist yet.
+	if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, encodeGolden(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("received response written to %s; rename it to %s to approve\n", filename, goldenFileName(name))
+}
+
+func readGolden(t *testing.T, filename string) []byte {
+	t.Helper()
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		fatalf(t, "%v", err)
+	}
+	if v, ok := goldenCache.Load(filename); ok {
+		if c := v.(cachedGolden); c.modTime.Equal(fi.ModTime()) && c.size == fi.Size() {
+			return c.data
+		}
+	}
+
+	data, err := readGoldenFile(filename, fi.Size())
 	if err != nil {
 		t.Fatal(err)
 	}
+	data, version, err := decodeGolden(data)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	if version < goldenFormat && *migrateGoldens {
+		writeGolden(t, filename, data)
+		t.Logf("migrated %s from golden format %d to %d\n", filename, version, goldenFormat)
+		return data
+	}
+	goldenCache.Store(filename, cachedGolden{modTime: fi.ModTime(), size: fi.Size(), data: data})
 	return data
 }
 
-func rewriteMap(t *testing.T, base, overwrite map[string]any, parents ...string) {
+// mmapThreshold is the size from which golden files are mapped into memory
+// instead of read, so that large responses are compared against the page
+// cache without copying them.
+const mmapThreshold = 16 << 10
+
+// readGoldenFile returns the content of the golden file of the size. Large
+// files are mapped into memory and never unmapped, as the cache and the
+// tests keep referring to them, unless golden files may be rewritten during
+// the run, which would truncate the mapped files.
+func readGoldenFile(filename string, size int64) ([]byte, error) {
+	if size < mmapThreshold || *updateGolden || *migrateGoldens {
+		return os.ReadFile(filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := mapFile(f, size)
+	if err != nil {
+		return os.ReadFile(filename)
+	}
+	return data, nil
+}
+
+func rewriteMap(t *testing.T, r *http.Response, base, overwrite map[string]any, parents ...string) {
 	t.Helper()
 
 	for k, v := range overwrite {
@@ -158,7 +498,7 @@ func rewriteMap(t *testing.T, base, overwrite map[string]any, parents ...string)
 				if !ok {
 					t.Fatalf("could not rewrite map: key = %q", strings.Join(append(parents, k), "."))
 				}
-				rewriteMap(t, sub, v, append(parents, k)...)
+				rewriteMap(t, r, sub, v, append(parents, k)...)
 			case []map[string]any:
 				sub, ok := old.([]any) // body is []any.
 				if !ok {
@@ -174,28 +514,92 @@ func rewriteMap(t *testing.T, base, overwrite map[string]any, parents ...string)
 					if !ok {
 						t.Fatalf("could not rewrite array map: key = %q", strings.Join(append(parents, kk), "."))
 					}
-					rewriteMap(t, sub2, vv, append(parents, kk)...)
+					rewriteMap(t, r, sub2, vv, append(parents, kk)...)
 				}
 			default:
 				base[k] = v
 			}
+		} else if *strictMode {
+			errorf(t, r, "ModifyJSON: key %q matched nothing\n", strings.Join(append(parents, k), "."))
 		}
 	}
 }
 
 // ModifyJSON overwrites the specified key in the JSON field of the response
 // body if it exists. When the map value of overwrite is map[string]any,
-// change only the specified fields.
+// change only the specified fields. The keys are relative to the payload of
+// the registered Envelope. When the body or the payload is an array, e.g. of
+// a list endpoint, the keys are overwritten in every object of the array.
+// With -strict, keys that do not exist fail the test.
 func ModifyJSON(overwrite map[string]any) ResponseFilter {
 	return func(t *testing.T, r *http.Response) {
 		t.Helper()
 
-		var tmp map[string]any
+		if bodyless(r.StatusCode) {
+			return
+		}
+		var tmp any
 		if err := json.NewDecoder(r.Body).Decode(&tmp); err != nil {
 			t.Fatal(err)
 		}
 
-		rewriteMap(t, tmp, overwrite)
+		switch v := payload(runnerOf(r.Request).envelope, tmp).(type) {
+		case map[string]any:
+			rewriteMap(t, r, v, overwrite)
+		case []any:
+			for i, elem := range v {
+				obj, ok := elem.(map[string]any)
+				if !ok {
+					t.Fatalf("could not rewrite array map: key = %q", "#"+strconv.Itoa(i))
+				}
+				rewriteMap(t, r, obj, overwrite, "#"+strconv.Itoa(i))
+			}
+		default:
+			if len(overwrite) > 0 && *strictMode {
+				errorf(t, r, "ModifyJSON: body is not a JSON object or array: %v\n", v)
+			}
+		}
+
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(body)
+	}
+}
+
+// IgnoreFields deletes the fields at the paths, in the syntax of Capture,
+// e.g. "created_time" or "meta.trace_id", from the JSON response body, so
+// that volatile fields are left out of the golden file instead of being
+// overwritten with fake values. The paths are relative to the payload of the
+// registered Envelope. With -strict, paths that do not exist fail the test.
+func IgnoreFields(paths ...string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		var tmp any
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+
+		v := payload(runnerOf(r.Request).envelope, tmp)
+		for _, p := range paths {
+			path := parsePath(p)
+			if _, ok := lookupPath(v, path); !ok {
+				if *strictMode {
+					errorf(t, r, "IgnoreFields: path %q matched nothing\n", p)
+				}
+				continue
+			}
+			if err := mutate(v, path, DeleteField(p)); err != nil {
+				t.Fatalf("IgnoreFields: %v\n", err)
+			}
+		}
 
 		body := new(bytes.Buffer)
 		if err := json.NewEncoder(body).Encode(&tmp); err != nil {
@@ -205,15 +609,37 @@ func ModifyJSON(overwrite map[string]any) ResponseFilter {
 	}
 }
 
-// PrettyJSON is a ResponseFilter for formatting JSON responses. It adds
-// indentation if the status code is not 204.
+// RedactPattern returns a ResponseFilter replacing the substrings of the
+// response body matching re with replacement, in which $1 and ${name} expand
+// to submatches as in regexp.Regexp.ReplaceAll, e.g. for tokens, UUIDs and
+// timestamps in text or HTML bodies ModifyJSON cannot rewrite. The body is
+// rewritten regardless of its content type. With -strict, patterns matching
+// nothing fail the test.
+func RedactPattern(re *regexp.Regexp, replacement string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.Match(body) && *strictMode {
+			errorf(t, r, "RedactPattern: %q matched nothing\n", re)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(re.ReplaceAll(body, []byte(replacement))))
+	}
+}
+
+// PrettyJSON is a ResponseFilter for formatting JSON responses, including
+// media types with the +json suffix such as application/problem+json. It adds
+// indentation unless the status code never has a body, e.g. 204 and 304.
 func PrettyJSON(t *testing.T, r *http.Response) {
 	t.Helper()
 
-	if r.StatusCode == http.StatusNoContent {
+	if bodyless(r.StatusCode) {
 		return
 	}
-	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+	if !isJSON(r.Header.Get("Content-Type")) {
 		t.Fatal("Response is not JSON")
 	}
 	body, err := io.ReadAll(r.Body)
@@ -223,19 +649,87 @@ func PrettyJSON(t *testing.T, r *http.Response) {
 	r.Body = io.NopCloser(bytes.NewReader(indentJSON(t, body)))
 }
 
-// CaptureResponse unmarshals JSON response.
-func CaptureResponse[T any](ptr *T) ResponseFilter {
+// ExpectHeader is a ResponseFilter asserting that the header key of the
+// response is value. With -strict, applying it to a 204 response without
+// headers fails the test too, since such an assertion is usually dead.
+func ExpectHeader(key, value string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if got := r.Header.Get(key); got != value {
+			errorf(t, r, "HTTP Header %s: %q, want: %q\n", key, got, value)
+		}
+		if *strictMode && r.StatusCode == http.StatusNoContent && len(r.Header) == 0 {
+			errorf(t, r, "ExpectHeader(%q) applied to a 204 response without headers\n", key)
+		}
+	}
+}
+
+// ExpectProtocol is a ResponseFilter asserting that the response was sent
+// with the protocol proto, e.g. "HTTP/2.0".
+func ExpectProtocol(proto string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if r.Proto != proto {
+			errorf(t, r, "HTTP Protocol: %s, want: %s\n", r.Proto, proto)
+		}
+	}
+}
+
+// ExpectTrailer is a ResponseFilter asserting that the trailer key of the
+// response, sent after the body, e.g. a checksum of a streamed body, is
+// value.
+func ExpectTrailer(key, value string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		// Trailers are known once the body is read.
+		readBody(t, r)
+		if got := r.Trailer.Get(key); got != value {
+			errorf(t, r, "HTTP Trailer %s: %q, want: %q\n", key, got, value)
+		}
+	}
+}
+
+// CaptureResponse unmarshals JSON response, or its payload when the response
+// is wrapped in the registered Envelope. T is a slice or a scalar type for
+// top-level arrays and scalars, e.g. []User of a list endpoint.
+func CaptureResponse[T any](ptr *T, opts ...CaptureOption) ResponseFilter {
+	var cfg captureConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(t *testing.T, r *http.Response) {
 		t.Helper()
 
+		if bodyless(r.StatusCode) {
+			return
+		}
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			t.Fatal(err)
 		}
 		r.Body = io.NopCloser(bytes.NewReader(body))
-		if err := json.Unmarshal(body, &ptr); err != nil {
+
+		body = payloadJSON(runnerOf(r.Request).envelope, body)
+		dec := json.NewDecoder(bytes.NewReader(body))
+		if cfg.disallowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&ptr); err != nil {
 			t.Fatal(err)
 		}
+		if cfg.requireFields {
+			var v any
+			if err := json.Unmarshal(body, &v); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range missingFields(reflect.TypeFor[T](), v, "") {
+				errorf(t, r, "CaptureResponse: required field %q is missing\n", f)
+			}
+		}
 	}
 }
 
@@ -259,16 +753,163 @@ func WithHeader(key, value string) RequestOption {
 	}
 }
 
-// NewRequest creates a new HTTP request and applies options.
+// WithHost sets the host the request is sent to, e.g. for virtual host
+// routing, instead of example.com.
+func WithHost(host string) RequestOption {
+	return func(r *http.Request) {
+		r.Host = host
+	}
+}
+
+// WithRemoteAddr sets the address the request is sent from in the form
+// "IP:port", e.g. for IP allow lists, instead of 192.0.2.1:1234. It has no
+// effect on requests sent over a socket.
+func WithRemoteAddr(addr string) RequestOption {
+	return func(r *http.Request) {
+		r.RemoteAddr = addr
+	}
+}
+
+// WithGzipBody compresses the body of the request with gzip and sets
+// Content-Encoding, for endpoints accepting compressed uploads.
+func WithGzipBody() RequestOption {
+	return func(r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			panic("e2e: WithGzipBody applied to a request without body")
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := io.Copy(zw, r.Body); err != nil {
+			panic(fmt.Sprintf("e2e: WithGzipBody: %v", err))
+		}
+		if err := zw.Close(); err != nil {
+			panic(fmt.Sprintf("e2e: WithGzipBody: %v", err))
+		}
+		r.Body = io.NopCloser(&buf)
+		r.ContentLength = int64(buf.Len())
+		r.Header.Set("Content-Encoding", "gzip")
+	}
+}
+
+// WithContext attaches ctx to the request, e.g. with the values read by
+// middleware. The deadline and cancellation are those of ctx, while the
+// values set by other options, such as Target, are kept.
+func WithContext(ctx context.Context) RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(mergedContext{Context: ctx, base: r.Context()})
+	}
+}
+
+// mergedContext is a context.Context looking up the values missing in its
+// context in base.
+type mergedContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c mergedContext) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.base.Value(key)
+}
+
+// WithBasicAuth sets the Authorization header to use HTTP Basic
+// Authentication with the username and password.
+func WithBasicAuth(username, password string) RequestOption {
+	return func(r *http.Request) {
+		r.SetBasicAuth(username, password)
+	}
+}
+
+// WithBearerToken sets the Authorization header to the bearer token.
+func WithBearerToken(token string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithCookie adds the cookie to the Cookie header.
+func WithCookie(c *http.Cookie) RequestOption {
+	return func(r *http.Request) {
+		r.AddCookie(c)
+	}
+}
+
+// WithCookies adds the cookies to the Cookie header.
+func WithCookies(cs ...*http.Cookie) RequestOption {
+	return func(r *http.Request) {
+		for _, c := range cs {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// Body is a request body knowing its media type, such as the one returned
+// by JSONBody.
+type Body interface {
+	io.Reader
+	ContentType() string
+}
+
+type typedBody struct {
+	*bytes.Buffer
+	contentType string
+}
+
+func (b typedBody) ContentType() string {
+	return b.contentType
+}
+
+// NewBody returns data as a Body of the media type, e.g. for helpers
+// encoding request bodies of other formats.
+func NewBody(data []byte, contentType string) Body {
+	return typedBody{Buffer: bytes.NewBuffer(data), contentType: contentType}
+}
+
+// WithContentType overrides the Content-Type set by the body, or removes it
+// when contentType is empty, e.g. to test 415 responses.
+func WithContentType(contentType string) RequestOption {
+	return func(r *http.Request) {
+		if contentType == "" {
+			r.Header.Del("Content-Type")
+			return
+		}
+		r.Header.Set("Content-Type", contentType)
+	}
+}
+
+// NewRequest creates a new HTTP request and applies options. When body is a
+// Body, its media type is the default Content-Type.
 func NewRequest(method, endpoint string, body io.Reader, options ...RequestOption) *http.Request {
-	r := httptest.NewRequest(method, endpoint, body)
+	validateEndpoint(endpoint)
+	reader := body
+	if b, ok := body.(typedBody); ok {
+		// Unwrapped so that httptest knows the Content-Length.
+		reader = b.Buffer
+	}
+	r := httptest.NewRequest(method, endpoint, reader)
+	if runIDHeader != "" {
+		r.Header.Set(runIDHeader, RunID())
+	}
+	if b, ok := body.(Body); ok {
+		r.Header.Set("Content-Type", b.ContentType())
+	}
 	for _, opt := range options {
 		opt(r)
 	}
 	return r
 }
 
-// JSONBody encodes m and returns it as an io.Reader.
+// FormBody encodes values and returns it as a Body of
+// application/x-www-form-urlencoded.
+func FormBody(t *testing.T, values url.Values) io.Reader {
+	t.Helper()
+
+	return typedBody{Buffer: bytes.NewBufferString(values.Encode()), contentType: "application/x-www-form-urlencoded"}
+}
+
+// JSONBody encodes m and returns it as a Body of application/json.
 func JSONBody(t *testing.T, m map[string]any) io.Reader {
 	t.Helper()
 
@@ -276,5 +917,5 @@ func JSONBody(t *testing.T, m map[string]any) io.Reader {
 	if err := json.NewEncoder(body).Encode(&m); err != nil {
 		t.Fatal(err)
 	}
-	return body
+	return typedBody{Buffer: body, contentType: "application/json"}
 }
@@ -0,0 +1,425 @@
+// Package e2egrpc tests gRPC services with golden files like e2e tests HTTP
+// handlers. The services are served in process over bufconn, and the
+// response message, status and metadata are written to the golden file of
+// the test deterministically, so the -golden workflow of e2e applies as is.
+package e2egrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/satorunooshie/e2e"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const bufSize = 1 << 20
+
+var server struct {
+	mu       sync.Mutex
+	register func(*grpc.Server)
+	opts     []grpc.ServerOption
+	conn     *grpc.ClientConn
+}
+
+// RegisterServer registers the services registered by register for RunTest.
+func RegisterServer(register func(s *grpc.Server), opts ...grpc.ServerOption) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.register = register
+	server.opts = opts
+	server.conn = nil
+}
+
+// conn returns the connection to the registered server, starting the server
+// on first use.
+func conn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.conn != nil {
+		return server.conn
+	}
+	if server.register == nil {
+		t.Fatal("e2egrpc: no server registered")
+	}
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer(server.opts...)
+	server.register(s)
+	go func() { _ = s.Serve(lis) }()
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.conn = cc
+	return cc
+}
+
+// Response is the result of a call passed to the filters.
+type Response struct {
+	Status  *status.Status
+	Header  metadata.MD
+	Trailer metadata.MD
+	// Message is the response message of a unary call, which is only
+	// written to the golden file when the call succeeded.
+	Message proto.Message
+	// Messages are the response messages of a streaming call in the order
+	// they were received, which are written to the golden file even when
+	// the call failed partway.
+	Messages []proto.Message
+}
+
+// Filter modifies the response before it is written to the golden file,
+// e.g. to clear fields changing on every call.
+type Filter func(t *testing.T, r *Response)
+
+// IgnoreMetadata removes the header and trailer keys from the response.
+func IgnoreMetadata(keys ...string) Filter {
+	return func(t *testing.T, r *Response) {
+		for _, k := range keys {
+			r.Header.Delete(k)
+			r.Trailer.Delete(k)
+		}
+	}
+}
+
+// MaskMetadata replaces the values of the header and trailer keys with
+// "***", e.g. request IDs, so that the golden file keeps that the keys are
+// sent without their values changing on every call.
+func MaskMetadata(keys ...string) Filter {
+	return func(t *testing.T, r *Response) {
+		for _, k := range keys {
+			for _, md := range []metadata.MD{r.Header, r.Trailer} {
+				if len(md.Get(k)) > 0 {
+					md.Set(k, "***")
+				}
+			}
+		}
+	}
+}
+
+// ExpectHeader asserts that the header key of the response is value.
+func ExpectHeader(key, value string) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		if got := strings.Join(r.Header.Get(key), ", "); got != value {
+			t.Errorf("gRPC header %s: %q, want: %q\n", key, got, value)
+		}
+	}
+}
+
+// ExpectTrailer asserts that the trailer key of the response is value.
+func ExpectTrailer(key, value string) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		if got := strings.Join(r.Trailer.Get(key), ", "); got != value {
+			t.Errorf("gRPC trailer %s: %q, want: %q\n", key, got, value)
+		}
+	}
+}
+
+// ExpectDetail asserts that the error details of the status include a
+// message equal to detail, e.g. a google.rpc.BadRequest with the violations
+// of the request. Filters normalizing details must be applied before.
+func ExpectDetail(detail proto.Message) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		name := detail.ProtoReflect().Descriptor().FullName()
+		var found bool
+		for _, a := range r.Status.Proto().GetDetails() {
+			if a.MessageName() != name {
+				continue
+			}
+			found = true
+			m, err := a.UnmarshalNew()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proto.Equal(m, detail) {
+				return
+			}
+		}
+		if found {
+			t.Errorf("gRPC status detail %s differs from %v\n", name, detail)
+			return
+		}
+		t.Errorf("gRPC status detail %s is missing\n", name)
+	}
+}
+
+// ModifyDetails overwrites the fields of the error details of the status in
+// their protojson form like ModifyMessages, e.g. {"requestId": "0"} for
+// google.rpc.RequestInfo.
+func ModifyDetails(overwrite map[string]any) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		p := r.Status.Proto()
+		for i, a := range p.GetDetails() {
+			m, err := a.UnmarshalNew()
+			if err != nil {
+				t.Fatal(err)
+			}
+			modifyMessage(t, m, overwrite)
+			if p.Details[i], err = anypb.New(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Status = status.FromProto(p)
+	}
+}
+
+// ModifyMessages overwrites the fields of the response messages in their
+// protojson form, like e2e.ModifyJSON does with JSON bodies, e.g. to clear
+// timestamps of every message of a stream. When the map value of overwrite
+// is map[string]any, only the specified fields of the object are changed.
+// Fields not in a message are left as is.
+func ModifyMessages(overwrite map[string]any) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		if r.Message != nil && r.Status.Code() == codes.OK {
+			modifyMessage(t, r.Message, overwrite)
+		}
+		for _, m := range r.Messages {
+			modifyMessage(t, m, overwrite)
+		}
+	}
+}
+
+// Capture returns a Filter setting the variable name of the scenario to the
+// field at path of the response message in its protojson form, like
+// e2e.Scenario.Capture does with JSON responses, so that a gRPC step passes
+// values to the later steps of any protocol. The last message of a stream is
+// captured. Failed calls capture nothing.
+func Capture(s *e2e.Scenario, name, path string) Filter {
+	return func(t *testing.T, r *Response) {
+		t.Helper()
+
+		m := r.Message
+		if n := len(r.Messages); n > 0 {
+			m = r.Messages[n-1]
+		}
+		if m == nil || r.Status.Code() != codes.OK {
+			return
+		}
+		data, err := protojson.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.CaptureJSON(t, name, path, data)
+	}
+}
+
+func modifyMessage(t *testing.T, m proto.Message, overwrite map[string]any) {
+	t.Helper()
+
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	overwriteFields(v, overwrite)
+	if data, err = json.Marshal(v); err != nil {
+		t.Fatal(err)
+	}
+	proto.Reset(m)
+	if err := protojson.Unmarshal(data, m); err != nil {
+		t.Fatalf("ModifyMessages: %v\n", err)
+	}
+}
+
+func overwriteFields(v, overwrite map[string]any) {
+	for k, o := range overwrite {
+		old, ok := v[k]
+		if !ok {
+			continue
+		}
+		sub, isMap := o.(map[string]any)
+		if oldSub, ok := old.(map[string]any); ok && isMap {
+			overwriteFields(oldSub, sub)
+			continue
+		}
+		v[k] = o
+	}
+}
+
+// RunTest invokes the full method name, e.g. "/user.v1.UserService/GetUser",
+// with req on the registered server, decoding the response into resp. It
+// checks the status code and compares the status, metadata and response
+// message with the golden file, or updates it with -golden.
+func RunTest(t *testing.T, method string, req, resp proto.Message, want codes.Code, filters ...Filter) {
+	t.Helper()
+
+	t.Logf(">>> %s\n", method)
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "x-e2e-run-id", e2e.RunID())
+	var header, trailer metadata.MD
+	err := conn(t).Invoke(ctx, method, req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+
+	got := &Response{Status: status.Convert(err), Header: header, Trailer: trailer, Message: resp}
+	if got.Status.Code() != want {
+		t.Errorf("gRPC status code: %s, want: %s\n", got.Status.Code(), want)
+	}
+	for _, f := range filters {
+		f(t, got)
+	}
+	e2e.CompareGolden(t, dump(t, got))
+}
+
+// RunStream invokes the full name of a server-streaming or bidi-streaming
+// method on the registered server, sending reqs in order and closing the send
+// direction, and receiving messages of the type of resp until the stream
+// ends. It checks the final status code and compares the status, metadata
+// and the ordered sequence of received messages with the golden file, or
+// updates it with -golden. Server-streaming methods take exactly one request.
+func RunStream(t *testing.T, method string, reqs []proto.Message, resp proto.Message, want codes.Code, filters ...Filter) {
+	t.Helper()
+
+	t.Logf(">>> %s (stream)\n", method)
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(t.Context(), "x-e2e-run-id", e2e.RunID()))
+	defer cancel()
+	got := &Response{}
+	stream, err := conn(t).NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	if err == nil {
+		// Requests are sent concurrently, so that bidi methods replying to
+		// each request before reading the next do not block.
+		sent := make(chan error, 1)
+		go func() {
+			for _, req := range reqs {
+				if err := stream.SendMsg(req); err != nil {
+					// The error is reported by RecvMsg.
+					break
+				}
+			}
+			sent <- stream.CloseSend()
+		}()
+		for {
+			m := resp.ProtoReflect().New().Interface()
+			if err = stream.RecvMsg(m); err != nil {
+				break
+			}
+			got.Messages = append(got.Messages, m)
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		cancel()
+		<-sent
+		got.Header, _ = stream.Header()
+		got.Trailer = stream.Trailer()
+	}
+	got.Status = status.Convert(err)
+
+	if got.Status.Code() != want {
+		t.Errorf("gRPC status code: %s, want: %s\n", got.Status.Code(), want)
+	}
+	for _, f := range filters {
+		f(t, got)
+	}
+	e2e.CompareGolden(t, dump(t, got))
+}
+
+// dump serializes r deterministically: protojson output is normalized, since
+// it varies its whitespace on purpose.
+func dump(t *testing.T, r *Response) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Status: %s\n", r.Status.Code())
+	if msg := r.Status.Message(); msg != "" {
+		fmt.Fprintf(&buf, "Message: %s\n", msg)
+	}
+	for _, a := range r.Status.Proto().GetDetails() {
+		m, err := a.UnmarshalNew()
+		if err != nil {
+			t.Fatalf("gRPC status detail %s: %v", a.GetTypeUrl(), err)
+		}
+		fmt.Fprintf(&buf, "Detail: %s\n", a.MessageName())
+		writeMessage(t, &buf, m)
+		buf.WriteString("\n")
+	}
+	writeMetadata(&buf, "Header", r.Header)
+	writeMetadata(&buf, "Trailer", r.Trailer)
+	if r.Message == nil {
+		for i, m := range r.Messages {
+			fmt.Fprintf(&buf, "\n# message %d\n", i+1)
+			writeMessage(t, &buf, m)
+		}
+		return buf.Bytes()
+	}
+	if r.Status.Code() != codes.OK {
+		return buf.Bytes()
+	}
+
+	buf.WriteString("\n")
+	writeMessage(t, &buf, r.Message)
+	return buf.Bytes()
+}
+
+func writeMessage(t *testing.T, buf *bytes.Buffer, m proto.Message) {
+	t.Helper()
+
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Indent(buf, data, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeMetadata(buf *bytes.Buffer, name string, md metadata.MD) {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		values := md[k]
+		switch {
+		case k == "grpc-status-details-bin":
+			// The details are written decoded with the status.
+			continue
+		case strings.HasSuffix(k, "-bin"):
+			values = make([]string, len(md[k]))
+			for i, v := range md[k] {
+				values[i] = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+		}
+		fmt.Fprintf(buf, "%s: %s: %s\n", name, k, strings.Join(values, ", "))
+	}
+}
@@ -0,0 +1,82 @@
+// Package e2eproto builds protobuf request bodies and writes protobuf
+// responses to golden files as readable text, so that e2e tests of endpoints
+// speaking application/x-protobuf depend on protobuf only when they import it.
+package e2eproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"testing"
+
+	"github.com/satorunooshie/e2e"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// protobufContentType is the media type of protobuf bodies.
+const protobufContentType = "application/x-protobuf"
+
+// Body encodes msg deterministically and returns it as an e2e.Body of
+// application/x-protobuf.
+func Body(t *testing.T, msg proto.Message) io.Reader {
+	t.Helper()
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e2e.NewBody(data, protobufContentType)
+}
+
+// Pretty returns an e2e.ResponseFilter decoding application/x-protobuf
+// bodies into the type of msg and writing them as indented protojson, so that
+// golden files hold readable text instead of raw bytes. When msg is nil, the
+// type is the message registered in protoregistry.GlobalTypes under the
+// full name in the messageType parameter of Content-Type, e.g.
+// "application/x-protobuf; messageType=example.v1.User".
+func Pretty(msg proto.Message) e2e.ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if r.StatusCode < 200 || r.StatusCode == http.StatusNoContent || r.StatusCode == http.StatusNotModified {
+			return
+		}
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != protobufContentType {
+			t.Fatal("Response is not protobuf")
+		}
+		var m proto.Message
+		if msg != nil {
+			m = msg.ProtoReflect().New().Interface()
+		} else {
+			mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(params["messagetype"]))
+			if err != nil {
+				t.Fatalf("Pretty: message type of %q: %v\n", r.Header.Get("Content-Type"), err)
+			}
+			m = mt.New().Interface()
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proto.Unmarshal(body, m); err != nil {
+			t.Fatal(err)
+		}
+		data, err := protojson.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// protojson varies its whitespace on purpose.
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(&buf)
+	}
+}
@@ -0,0 +1,139 @@
+// Package e2ews tests WebSocket endpoints of the router registered to e2e
+// with golden files: it connects to the endpoint over a real socket, sends a
+// scripted sequence of messages and writes the received message stream to
+// the golden file of the test.
+package e2ews
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/satorunooshie/e2e"
+)
+
+// Step is a step of the script of RunTest.
+type Step struct {
+	// Send is the message sent, if not empty.
+	Send string
+	// Binary sends Send as a binary message instead of a text message.
+	Binary bool
+	// Receive is the number of messages read after sending.
+	Receive int
+}
+
+// Option configures RunTest.
+type Option func(*config)
+
+type config struct {
+	srv     *httptest.Server
+	header  http.Header
+	timeout time.Duration
+}
+
+// WithServer connects to srv instead of a server started for the test, e.g.
+// one started by e2e.StartServer with options.
+func WithServer(srv *httptest.Server) Option {
+	return func(c *config) {
+		c.srv = srv
+	}
+}
+
+// WithHeader sets a header of the handshake request, e.g. for
+// authentication.
+func WithHeader(key, value string) Option {
+	return func(c *config) {
+		c.header.Add(key, value)
+	}
+}
+
+// ReadTimeout limits the time waiting for each message. The default is 5
+// seconds.
+func ReadTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// RunTest connects to the WebSocket endpoint of the registered router, runs
+// script and compares the handshake status and the messages sent and
+// received with the golden file, or updates it with -golden. Sent messages
+// are prefixed with "> " and received ones with "< ". The connection is
+// closed normally at the end of the script, and the close frame of the
+// server is recorded if it closed first.
+func RunTest(t *testing.T, endpoint string, script []Step, opts ...Option) {
+	t.Helper()
+
+	cfg := config{header: make(http.Header), timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.srv == nil {
+		cfg.srv = e2e.StartServer(t)
+	}
+
+	t.Logf(">>> WS %s\n", endpoint)
+	url := "ws" + strings.TrimPrefix(cfg.srv.URL, "http") + endpoint
+	conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), url, cfg.header)
+	var buf bytes.Buffer
+	if resp != nil {
+		fmt.Fprintf(&buf, "%s\n", resp.Status)
+	}
+	if err != nil {
+		fmt.Fprintf(&buf, "handshake failed: %v\n", err)
+		e2e.CompareGolden(t, buf.Bytes())
+		return
+	}
+	defer conn.Close()
+
+	closed := false
+	for _, step := range script {
+		if closed {
+			break
+		}
+		if step.Send != "" {
+			typ := websocket.TextMessage
+			if step.Binary {
+				typ = websocket.BinaryMessage
+			}
+			if err := conn.WriteMessage(typ, []byte(step.Send)); err != nil {
+				t.Fatal(err)
+			}
+			writeMessage(&buf, ">", typ, []byte(step.Send))
+		}
+		for range step.Receive {
+			if err := conn.SetReadDeadline(time.Now().Add(cfg.timeout)); err != nil {
+				t.Fatal(err)
+			}
+			typ, data, err := conn.ReadMessage()
+			if ce := new(websocket.CloseError); errors.As(err, &ce) {
+				fmt.Fprintf(&buf, "closed by server: %d %s\n", ce.Code, ce.Text)
+				closed = true
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeMessage(&buf, "<", typ, data)
+		}
+	}
+	if !closed {
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(cfg.timeout))
+	}
+	e2e.CompareGolden(t, buf.Bytes())
+}
+
+func writeMessage(buf *bytes.Buffer, dir string, typ int, data []byte) {
+	if typ == websocket.BinaryMessage {
+		fmt.Fprintf(buf, "%s binary %x\n", dir, data)
+		return
+	}
+	fmt.Fprintf(buf, "%s %s\n", dir, data)
+}
@@ -0,0 +1,82 @@
+package e2e
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// ContentDecoder returns a reader decoding r of a Content-Encoding.
+type ContentDecoder func(r io.Reader) (io.Reader, error)
+
+// contentDecoders are the decoders of Content-Encoding tokens. deflate is
+// the zlib format in HTTP.
+var contentDecoders = map[string]ContentDecoder{
+	"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"x-gzip":  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+}
+
+// RegisterContentDecoder registers the decoder of the Content-Encoding
+// token, e.g. "br" with a brotli package, or "zstd". gzip and deflate are
+// decoded by default.
+func RegisterContentDecoder(encoding string, d ContentDecoder) {
+	contentDecoders[strings.ToLower(encoding)] = d
+}
+
+// decodeContentEncoding decodes the body of r of the codings of
+// Content-Encoding before the filters and the golden comparison, so that
+// golden files hold readable bodies instead of compressed bytes varying with
+// the compression library. Content-Encoding is removed, and the decoded
+// codings are annotated in the X-E2e-Decoded-From header. Codings without a
+// registered decoder are left as is.
+func decodeContentEncoding(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	var codings []string
+	for _, v := range r.Header.Values("Content-Encoding") {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+				codings = append(codings, c)
+			}
+		}
+	}
+	if len(codings) == 0 || bodyless(r.StatusCode) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Codings are listed in the order they were applied.
+	n := len(codings)
+	for ; n > 0; n-- {
+		decode, ok := contentDecoders[codings[n-1]]
+		if !ok {
+			t.Logf("Content-Encoding %s is not decoded; register it with RegisterContentDecoder\n", codings[n-1])
+			break
+		}
+		dr, err := decode(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("decoding Content-Encoding %s: %v\n", codings[n-1], err)
+		}
+		if body, err = io.ReadAll(dr); err != nil {
+			t.Fatalf("decoding Content-Encoding %s: %v\n", codings[n-1], err)
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if n == len(codings) {
+		return
+	}
+
+	r.Header.Del("Content-Encoding")
+	if n > 0 {
+		r.Header.Set("Content-Encoding", strings.Join(codings[:n], ", "))
+	}
+	r.Header.Set("X-E2e-Decoded-From", strings.Join(codings[n:], ", "))
+}
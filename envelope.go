@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Envelope describes an envelope wrapping the payload of JSON responses,
+// such as {"data": ..., "meta": ...}.
+type Envelope struct {
+	// Data is the key of the payload. ModifyJSON and CaptureResponse address
+	// the payload instead of the whole body when the key exists.
+	Data string
+	// Meta overwrites the fields of every enveloped response like
+	// ModifyJSON before the filters run, normalizing noise such as request
+	// IDs and timings in one place.
+	Meta map[string]any
+}
+
+var envelope *Envelope
+
+// RegisterEnvelope registers the envelope of the JSON responses.
+func RegisterEnvelope(e Envelope) {
+	envelope = &e
+}
+
+// payload returns the payload of v when v is wrapped in envelope, or v
+// itself. The payload may be of any JSON type, e.g. a list of resources.
+func payload(envelope *Envelope, v any) any {
+	if envelope == nil {
+		return v
+	}
+	if m, ok := v.(map[string]any); ok {
+		if p, ok := m[envelope.Data]; ok {
+			return p
+		}
+	}
+	return v
+}
+
+// payloadJSON is like payload for an encoded JSON body.
+func payloadJSON(envelope *Envelope, body []byte) []byte {
+	if envelope == nil {
+		return body
+	}
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	if p, ok := v[envelope.Data]; ok {
+		return p
+	}
+	return body
+}
+
+// normalizeEnvelope overwrites the fields of the envelope with Meta.
+func normalizeEnvelope(t *testing.T, envelope *Envelope, r *http.Response) {
+	t.Helper()
+
+	if envelope == nil || len(envelope.Meta) == 0 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Top-level arrays and scalars are not enveloped.
+	var tmp any
+	if err := json.Unmarshal(body, &tmp); err != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	v, ok := tmp.(map[string]any)
+	if _, enveloped := v[envelope.Data]; !ok || !enveloped {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+
+	rewriteMap(t, r, v, envelope.Meta)
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+	r.Body = io.NopCloser(buf)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// userServiceDesc describes a user service built from well-known types, so
+// that the example needs no generated code.
+var userServiceDesc = grpc.ServiceDesc{
+	ServiceName: "example.v1.UserService",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUser",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				id := new(wrapperspb.Int64Value)
+				if err := dec(id); err != nil {
+					return nil, err
+				}
+				return getUser(ctx, id)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListUsers",
+			ServerStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				in := new(emptypb.Empty)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return listUsers(stream)
+			},
+		},
+		{
+			StreamName:    "Chat",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				return chat(stream)
+			},
+		},
+	},
+}
+
+func getUser(ctx context.Context, id *wrapperspb.Int64Value) (*structpb.Struct, error) {
+	if id.GetValue() < 1 {
+		requestID := strconv.FormatInt(time.Now().UnixNano(), 36)
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("x-request-id", requestID))
+		st, err := status.New(codes.InvalidArgument, "invalid user id").WithDetails(
+			&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "value", Description: "must be positive"},
+			}},
+			&errdetails.RequestInfo{RequestId: requestID},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+	if id.GetValue() != 1 {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", id.GetValue())
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-user-version", "1"))
+	return structpb.NewStruct(map[string]any{"id": 1, "name": "JoJo"})
+}
+
+// listUsers streams the users with when each was sent, and fails after the
+// last one to show the status of a stream ending with an error.
+func listUsers(stream grpc.ServerStream) error {
+	_ = stream.SetHeader(metadata.Pairs("x-user-version", "1"))
+	for i, name := range []string{"Jonathan Joestar", "Joseph Joestar"} {
+		user, err := structpb.NewStruct(map[string]any{"id": i + 1, "name": name, "sent_at": time.Now().Format(time.RFC3339Nano)})
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(user); err != nil {
+			return err
+		}
+	}
+	return status.Error(codes.Unavailable, "the rest of the users are in the desert")
+}
+
+// chat replies to every message until the client closes the stream.
+func chat(stream grpc.ServerStream) error {
+	for {
+		in := new(wrapperspb.StringValue)
+		if err := stream.RecvMsg(in); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(wrapperspb.String("ORA " + in.GetValue())); err != nil {
+			return err
+		}
+	}
+}
+
+func registerGRPC(s *grpc.Server) {
+	s.RegisterService(&userServiceDesc, nil)
+}
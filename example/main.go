@@ -1,15 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func main() {
@@ -17,6 +38,7 @@ func main() {
 		Addr:    ":8080",
 		Handler: newRouter(),
 	}
+	configureServer(server)
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
@@ -37,6 +59,23 @@ func main() {
 	}
 }
 
+var (
+	readTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// configureServer applies the timeouts to protect the server from slow clients.
+func configureServer(s *http.Server) {
+	s.ReadTimeout = readTimeout
+	s.WriteTimeout = writeTimeout
+}
+
+const maxUserBodySize = 1 << 10
+
+var upgrader websocket.Upgrader
+
+var _, internalNetwork, _ = net.ParseCIDR("10.0.0.0/8")
+
 func newRouter() http.Handler {
 	mux := http.NewServeMux()
 
@@ -52,8 +91,18 @@ func newRouter() http.Handler {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// GET: http.StatusEarlyHints, http.StatusOK
+	mux.HandleFunc("/v1/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"title":"home"}`))
+	})
+
 	// GET: http.StatusOK
 	// PUT: http.StatusNoContent
+	// DELETE: http.StatusNoContent
 	mux.HandleFunc("/v1/user/1", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -69,17 +118,512 @@ func newRouter() http.Handler {
 				_, _ = w.Write([]byte(`{"name":"JoJo"}`))
 				w.WriteHeader(http.StatusOK)
 			}
-		case http.MethodPut:
+		case http.MethodPut, http.MethodDelete:
 			w.WriteHeader(http.StatusNoContent)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
 
+	// GET: http.StatusOK, for the admin with Basic Authentication or a bearer token
+	mux.HandleFunc("GET /v1/admin", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !(ok && user == "dio" && pass == "za warudo") && r.Header.Get("Authorization") != "Bearer dio-token" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte(`{"name":"DIO"}`))
+	})
+
+	// GET: http.StatusOK, streams users as JSON Lines
+	mux.HandleFunc("/v1/users/export", func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		// Exports may take longer than the server's write timeout.
+		_ = rc.SetWriteDeadline(time.Now().Add(time.Minute))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, name := range []string{"Jonathan Joestar", "Joseph Joestar", "Jotaro Kujo"} {
+			_ = json.NewEncoder(w).Encode(map[string]any{"name": name})
+			_ = rc.Flush()
+		}
+	})
+
+	// GET: http.StatusOK, downloads the users as a CSV file
+	mux.HandleFunc("GET /v1/users/export.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+		_, _ = w.Write([]byte("id,name\n1,Jonathan Joestar\n2,Joseph Joestar\n3,Jotaro Kujo\n"))
+	})
+
+	// GET: http.StatusOK, the card of the user in JSON or plain text in UTF-8,
+	// or http.StatusNotAcceptable listing the supported types
+	mux.HandleFunc("GET /v1/user/1/card", func(w http.ResponseWriter, r *http.Request) {
+		supported := []string{"application/json", "text/plain"}
+		typ := negotiate(r.Header.Get("Accept"), supported)
+		if cs := r.Header.Get("Accept-Charset"); cs != "" && negotiate(cs, []string{"utf-8"}) == "" {
+			typ = ""
+		}
+		switch typ {
+		case "application/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"JoJo","stand":"Star Platinum"}`))
+		case "text/plain":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte("JoJo (Star Platinum)\n"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotAcceptable)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "not acceptable", "supported": supported})
+		}
+	})
+
+	// GET: http.StatusOK, the server-rendered profile page of the user with a
+	// fresh script nonce and CSRF token, or only its form with partial=1
+	mux.HandleFunc("GET /v1/user/1/page", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UnixNano()
+		nonce := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%d", now))
+		form := fmt.Sprintf(`<form method="post"   action="/v1/user/1">
+    <input type="hidden" name="_csrf" value="%x">
+    <input name="name"
+           value="JoJo" type="text"><button>Save</button></form>`, now)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Query().Get("partial") == "1" {
+			_, _ = io.WriteString(w, form)
+			return
+		}
+		w.Header().Set("Content-Security-Policy", fmt.Sprintf("script-src 'self' 'nonce-%s'", nonce))
+		_, _ = fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en"><head><meta name="csrf-token" content="%x"><title>  JoJo
+</title>
+<script nonce="%s">console.log("<ora>");</script></head>
+<body class="profile" id="user-1">
+<h1>Jonathan   Joestar</h1><!-- stands -->
+<pre>  Hamon
+    Overdrive</pre>
+%s
+</body></html>`, now, nonce, form)
+	})
+
+	// GET: http.StatusOK, the profile, the motto and the stand of the user in
+	// one multipart/mixed response, like a batch API
+	mux.HandleFunc("GET /v1/user/1/bundle", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UnixNano()
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		part := func(contentType, body string) {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+			if err == nil {
+				_, _ = io.WriteString(pw, body)
+			}
+		}
+		part("application/json", fmt.Sprintf(`{"name":"JoJo","fetched_at":%d}`, now))
+		part("text/plain; charset=utf-8", "Ora ora ora!")
+		stand := fmt.Sprintf(`{"stand":"Star Platinum","fetched_at":%d}`, now)
+		part("application/http", fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(stand), stand))
+		_ = mw.Close()
+	})
+
+	// GET: http.StatusOK, the activity of the users as JSON Lines with when
+	// each happened
+	mux.HandleFunc("GET /v1/users/activity", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UnixNano()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = fmt.Fprintf(w, "{\"user\":\"Jotaro Kujo\",\"action\":\"login\",\"at\":%d}\n\n", now)
+		_, _ = fmt.Fprintf(w, "{\"user\":\"Josuke Higashikata\",\"action\":\"update\",\"at\":%d,\"trace\":{\"id\":\"%x\"}}\n", now, now)
+	})
+
+	// GET: http.StatusOK, wrapped in an envelope
+	mux.HandleFunc("/v2/user/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"data":{"id":1,"name":"JoJo","_links":{"self":{"href":"/v2/user/1"},"v1":{"href":"/v1/user/1"}}},"meta":{"request_id":"%d"}}`, time.Now().UnixNano())
+	})
+
+	// GET: http.StatusOK, the avatar of the user as a PNG image
+	mux.HandleFunc("GET /v1/user/1/avatar.png", func(w http.ResponseWriter, r *http.Request) {
+		img := image.NewGray(image.Rect(0, 0, 2, 2))
+		img.SetGray(0, 0, color.Gray{Y: 0xff})
+		img.SetGray(1, 1, color.Gray{Y: 0xff})
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, img)
+	})
+
+	// POST: http.StatusCreated, uploads the avatar of the user as multipart/form-data
+	mux.HandleFunc("POST /v1/user/avatar", func(w http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("avatar")
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		size, err := io.Copy(io.Discard, f)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"name":         r.FormValue("name"),
+			"filename":     h.Filename,
+			"content_type": h.Header.Get("Content-Type"),
+			"size":         size,
+		})
+	})
+
+	// GET: http.StatusOK, a greeting in the legacy encoding of the language
+	mux.HandleFunc("GET /v1/greeting", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("lang") {
+		case "ja":
+			w.Header().Set("Content-Type", "text/plain; charset=Shift_JIS")
+			_, _ = japanese.ShiftJIS.NewEncoder().Writer(w).Write([]byte("こんにちは、ジョジョ"))
+		case "fr":
+			w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+			_, _ = charmap.ISO8859_1.NewEncoder().Writer(w).Write([]byte("Bonjour, Jérôme"))
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte("Hello, JoJo"))
+		}
+	})
+
+	// POST: http.StatusOK, a legacy form post
+	mux.HandleFunc("POST /v1/contact", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("name") == "" {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"name":   r.PostForm.Get("name"),
+			"topics": r.PostForm["topic"],
+		})
+	})
+
+	// POST: http.StatusOK, the profile echoed, for JSON requests only
+	mux.HandleFunc("POST /v1/user/profile", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(req)
+	})
+
+	// GET: http.StatusOK, http.StatusNotModified, a static asset cacheable for a day
+	mux.HandleFunc("GET /static/logo.svg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("ETag", `"logo-v1"`)
+		if r.Header.Get("If-None-Match") == `"logo-v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		_, _ = w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`))
+	})
+
+	// POST: http.StatusOK, a SOAP-ish XML endpoint
+	mux.HandleFunc("POST /v1/soap/user", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID int `xml:"ID"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || req.ID != 1 {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_, _ = fmt.Fprint(w, xml.Header+`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<GetUserResponse version="1" id="1"><Name lang="en" kind="nickname">JoJo</Name><Stands/></GetUserResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+
+	// POST: http.StatusOK, the user of the ID as protobuf
+	mux.HandleFunc("POST /v1/user/proto", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		id := new(wrapperspb.Int64Value)
+		if err := proto.Unmarshal(body, id); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		user, err := getUser(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(user)
+		if err != nil {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf; messageType=google.protobuf.Struct")
+		_, _ = w.Write(data)
+	})
+
+	// GET: http.StatusOK, users paginated by opaque cursors in Link headers
+	mux.HandleFunc("GET /v1/users", func(w http.ResponseWriter, r *http.Request) {
+		users := []string{"Jonathan Joestar", "Joseph Joestar", "Jotaro Kujo", "Josuke Higashikata", "Giorno Giovanna"}
+		const pageSize = 2
+		offset := 0
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			data, err := base64.RawURLEncoding.DecodeString(c)
+			if err != nil {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			n, _, _ := strings.Cut(string(data), ":")
+			if offset, err = strconv.Atoi(n); err != nil || offset < 0 || offset >= len(users) {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+		}
+		// Cursors are opaque and differ on every response.
+		cursor := func(offset int) string {
+			return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d:%d", offset, time.Now().UnixNano()))
+		}
+		links := []string{`</v1/users>; rel="first"`}
+		if offset > 0 {
+			links = append(links, fmt.Sprintf(`</v1/users?cursor=%s>; rel="prev"`, cursor(max(offset-pageSize, 0))))
+		}
+		if offset+pageSize < len(users) {
+			links = append(links, fmt.Sprintf(`</v1/users?cursor=%s>; rel="next"`, cursor(offset+pageSize)))
+		}
+		w.Header().Set("Link", strings.Join(links, ", "))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(users[offset:min(offset+pageSize, len(users))])
+	})
+
+	// GET: http.StatusOK, the number of users as a bare JSON number
+	mux.HandleFunc("GET /v1/users/count", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(5)
+	})
+
+	// GET: http.StatusOK, the stand history of the user as a top-level array
+	mux.HandleFunc("GET /v1/user/1/stands/history", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().Unix()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"stand": "Hermit Purple", "awakened_at": now},
+			{"stand": "Star Platinum", "awakened_at": now},
+		})
+	})
+
+	// GET: http.StatusOK, the user by ID, or a problem detail of RFC 9457 for
+	// malformed and unknown IDs
+	// GET: http.StatusOK, the users in the envelope
+	mux.HandleFunc("GET /v2/users", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"data":[{"id":1,"name":"Jonathan Joestar","created_time":%d},{"id":2,"name":"Joseph Joestar","created_time":%d}],"meta":{"request_id":"%d"}}`, now.Unix(), now.Unix(), now.UnixNano())
+	})
+	mux.HandleFunc("GET /v2/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		users := map[int]string{1: "Jonathan Joestar", 2: "Joseph Joestar", 3: "Jotaro Kujo"}
+		problem := func(status int, detail string) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"type":     "about:blank",
+				"title":    http.StatusText(status),
+				"status":   status,
+				"detail":   detail,
+				"instance": r.URL.Path,
+			})
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			problem(http.StatusBadRequest, "id must be a number")
+			return
+		}
+		name, ok := users[id]
+		if !ok {
+			problem(http.StatusNotFound, fmt.Sprintf("user %d does not exist", id))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "name": name})
+	})
+
+	// GET: http.StatusOK, the virtual host and the client IP, from the internal
+	// network only
+	mux.HandleFunc("GET /v1/whoami", func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil || !internalNetwork.Contains(net.ParseIP(host)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"host": r.Host, "ip": host})
+	})
+
+	// GET: http.StatusOK, the stands of the user, compressed with gzip if
+	// accepted
+	mux.HandleFunc("GET /v1/user/1/stands", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Accept-Encoding")
+		var out io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			defer zw.Close()
+			out = zw
+		}
+		_ = json.NewEncoder(out).Encode(map[string]any{"stands": []string{"Star Platinum", "Hermit Purple"}})
+	})
+
+	// GET: http.StatusOK, the friends of the user with when they were fetched
+	mux.HandleFunc("GET /v1/user/1/friends", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UnixNano()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"items": []map[string]any{
+				{"user": map[string]any{"id": 2, "name": "Joseph Joestar"}, "fetched_at": now},
+				{"user": map[string]any{"id": 3, "name": "Jotaro Kujo"}, "fetched_at": now},
+			},
+			"trace": map[string]any{"id": fmt.Sprint(now), "fetched_at": now},
+		})
+	})
+
+	// GET: http.StatusOK, a plain text receipt with a fresh token
+	mux.HandleFunc("GET /v1/user/1/receipt", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = fmt.Fprintf(w, "Receipt for JoJo\nissued: %s\ntoken: tok_%x\nrequest: %08x-%04x-4000-8000-%012x\n",
+			now.UTC().Format(time.RFC3339), now.UnixNano(), now.Unix(), now.Nanosecond()&0xffff, now.UnixNano()&0xffffffffffff)
+	})
+
+	// GET: http.StatusOK, the badges of the user in the order of map iteration
+	mux.HandleFunc("GET /v1/user/1/badges", func(w http.ResponseWriter, r *http.Request) {
+		badges := map[int]string{1: "Ripple", 2: "Stand User", 3: "Joestar"}
+		var items []map[string]any
+		var tags []string
+		for id, name := range badges {
+			items = append(items, map[string]any{"id": id, "name": name})
+			tags = append(tags, strings.ToLower(name))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": items, "tags": tags})
+	})
+
+	// GET: http.StatusOK, the stats of the user serialized by hand
+	mux.HandleFunc("GET /v1/user/1/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"stand":"<Star Platinum>","speed":1.50,"power":15e-1,"ora":12345678901234567890,"rank":2.0E0}`))
+	})
+
+	// GET: http.StatusOK, the user of the legacy API, sunset in 2099
+	mux.HandleFunc("GET /v1/legacy/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@1672531200")
+		w.Header().Set("Sunset", "Thu, 01 Jan 2099 00:00:00 GMT")
+		w.Header().Set("Link", `<https://example.com/deprecations/legacy-user>; rel="deprecation"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"JoJo"}`))
+	})
+
+	// POST: http.StatusNoContent, starts a session
+	mux.HandleFunc("POST /v1/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "jojo", Path: "/", HttpOnly: true, Secure: true})
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// GET: http.StatusOK, the user of the session
+	mux.HandleFunc("GET /v1/me", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "jojo" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"JoJo"}`))
+	})
+
+	// GET: http.StatusOK, streams user events as server-sent events until
+	// the client disconnects
+	mux.HandleFunc("/v1/users/events", func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for _, name := range []string{"Jonathan Joestar", "Joseph Joestar", "Jotaro Kujo"} {
+			_, _ = fmt.Fprintf(w, "event: user\nid: %d\ndata: {\"name\":%q}\n\n", time.Now().UnixNano(), name)
+			_ = rc.Flush()
+		}
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				_, _ = fmt.Fprint(w, ": heartbeat\n\n")
+				_ = rc.Flush()
+			}
+		}
+	})
+
+	// GET: WebSocket echoing messages in upper case until "bye"
+	mux.HandleFunc("/v1/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(msg) == "bye" {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+				return
+			}
+			_ = conn.WriteMessage(typ, bytes.ToUpper(msg))
+		}
+	})
+
 	// POST: http.StatusCreated
 	mux.HandleFunc("/v1/user", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
+			if r.ContentLength > maxUserBodySize {
+				http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if ct := r.Header.Get("Content-Type"); ct != "" && ct != "application/json" {
+				http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+				return
+			}
+			body := r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					http.Error(w, "Bad request", http.StatusBadRequest)
+					return
+				}
+				body = zr
+			}
+			var req struct {
+				Name string `json:"name"`
+				Age  int    `json:"age"`
+			}
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusCreated)
 			_, _ = fmt.Fprintf(w, `{"id":1,"created_time":%d}`, time.Now().Unix())
@@ -89,3 +633,25 @@ func newRouter() http.Handler {
 	})
 	return mux
 }
+
+// negotiate returns the first of supported accepted by the Accept or
+// Accept-Charset header value, which accepts anything when empty, or an
+// empty string.
+func negotiate(accept string, supported []string) string {
+	if accept == "" {
+		return supported[0]
+	}
+	for _, s := range supported {
+		for _, v := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(v)
+			if err != nil || params["q"] == "0" {
+				continue
+			}
+			top, _, _ := strings.Cut(s, "/")
+			if mediaType == s || mediaType == "*/*" || mediaType == "*" || mediaType == top+"/*" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -1,18 +1,83 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/satorunooshie/e2e"
+	"github.com/satorunooshie/e2e/e2egrpc"
+	"github.com/satorunooshie/e2e/e2eproto"
+	"github.com/satorunooshie/e2e/e2ews"
+	"github.com/satorunooshie/e2e/filtertest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+//go:generate go run ../cmd/e2egen -spec openapi.json -pkg main
+
 func TestMain(m *testing.M) {
 	e2e.RegisterRouter(newRouter())
+	// The example router serves fixed users instead of querying a store.
+	e2e.ReportDependency("users", e2e.MockDependency, "fixed responses")
+	e2e.ReportDependency("grpc", e2e.RealDependency, "example.v1.UserService over bufconn")
+	e2e.RegisterEnvelope(e2e.Envelope{
+		Data: "data",
+		Meta: map[string]any{"meta": map[string]any{"request_id": "0"}},
+	})
+	e2e.RegisterDeprecation(e2e.Deprecation{
+		Pattern: "/v1/user/{id}",
+		Sunset:  time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC),
+	})
+	e2e.RegisterDeprecation(e2e.Deprecation{
+		Pattern: "GET /v1/legacy/user",
+		Sunset:  time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Link:    "https://example.com/deprecations/legacy-user",
+	})
+	e2e.RegisterNegotiation(e2e.Negotiation{
+		Pattern:  "GET /v1/user/1/card",
+		Types:    []string{"application/json", "text/plain"},
+		Charsets: []string{"utf-8"},
+	})
+
+	var collected string
+	e2e.RegisterGarbageCollector(func(prefix string) error {
+		// The example router keeps no resources; a real collector deletes
+		// the ones named with prefix.
+		collected = prefix
+		return nil
+	})
 
-	m.Run()
+	// The suite keeps well within a minute; WarnOnly reports the slowest
+	// tests instead of failing when it creeps over.
+	code := e2e.RunSuite(m, e2e.WithBudget(time.Minute), e2e.WarnOnly())
+	if collected != e2e.Namespace() {
+		fmt.Fprintf(os.Stderr, "garbage collected namespace %q, want: %q\n", collected, e2e.Namespace())
+		code = 1
+	}
+	os.Exit(code)
 }
 
 // APITestName returns golden file name.
@@ -70,25 +135,48 @@ func TestUserGetEndpoint(t *testing.T) {
 	}
 }
 
-// TestUserPostEndpoint shows ModifyJSON example.
+// TestUserPostEndpoint shows ModifyJSON and IgnoreFields example.
 func TestUserPostEndpoint(t *testing.T) {
 	const endpoint = "/v1/user"
 
 	tests := []struct {
 		description []string
 		body        map[string]any
+		options     []e2e.RequestOption
 		want        int
+		filters     []e2e.ResponseFilter
 	}{
 		{
 			description: []string{"success"},
 			body:        map[string]any{"name": "Jonathan Joestar"},
 			want:        http.StatusCreated,
+			filters:     []e2e.ResponseFilter{e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON},
+		},
+		{
+			description: []string{"gzip"},
+			body:        map[string]any{"name": "Jonathan Joestar"},
+			options:     []e2e.RequestOption{e2e.WithGzipBody()},
+			want:        http.StatusCreated,
+			filters:     []e2e.ResponseFilter{e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON},
+		},
+		{
+			description: []string{"ignore fields"},
+			body:        map[string]any{"name": "Jonathan Joestar"},
+			want:        http.StatusCreated,
+			filters:     []e2e.ResponseFilter{e2e.IgnoreFields("created_time"), e2e.PrettyJSON},
+		},
+		{
+			description: []string{"unsupported media type"},
+			body:        map[string]any{"name": "Jonathan Joestar"},
+			// Overrides the application/json set by JSONBody.
+			options: []e2e.RequestOption{e2e.WithContentType("text/plain")},
+			want:    http.StatusUnsupportedMediaType,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
-			r := e2e.NewRequest(http.MethodPost, endpoint, e2e.JSONBody(t, tt.body))
-			e2e.RunTest(t, r, tt.want, e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+			r := e2e.NewRequest(http.MethodPost, endpoint, e2e.JSONBody(t, tt.body), tt.options...)
+			e2e.RunTest(t, r, tt.want, tt.filters...)
 		})
 	}
 }
@@ -119,28 +207,2261 @@ func TestUserPutEndpoint(t *testing.T) {
 	}
 }
 
-// TestUserScenario shows a scenario testing example.
+// TestUserScenario shows a scenario testing example. Each step runs after
+// the previous one, so the reads see the state left by the writes.
 func TestUserScenario(t *testing.T) {
 	resp := struct{ ID int }{}
+	s := e2e.NewScenario()
 	// TestName: number methodName description
-	t.Run("1 UserPost registration", func(t *testing.T) {
+	s.Step("1 UserPost registration", func(t *testing.T) {
 		const endpoint = "/v1/user"
 		r := e2e.NewRequest(http.MethodPost, endpoint, e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
-		e2e.RunTest(t, r, http.StatusCreated, e2e.CaptureResponse(&resp), e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+		cleanup := e2e.DeleteOnCleanup(func() string { return "/v1/user/" + strconv.Itoa(resp.ID) })
+		s.Create(t, r, http.StatusCreated, cleanup, e2e.CaptureResponse(&resp, e2e.RequireFields()), e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
 	})
-	t.Run("2 UserGet after registration", func(t *testing.T) {
+	s.Step("2 UserGet after registration", func(t *testing.T) {
 		endpoint := "/v1/user/" + strconv.Itoa(resp.ID)
 		r := e2e.NewRequest(http.MethodGet, endpoint, nil)
 		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
 	})
-	t.Run("3 UserPut update user name", func(t *testing.T) {
+	s.Step("3 UserPut update user name", func(t *testing.T) {
 		endpoint := "/v1/user/" + strconv.Itoa(resp.ID)
 		r := e2e.NewRequest(http.MethodPut, endpoint, e2e.JSONBody(t, map[string]any{"name": "Giorno Giovanna"}))
 		e2e.RunTest(t, r, http.StatusNoContent)
 	})
-	t.Run("4 UserGet after user name update", func(t *testing.T) {
+	s.Step("4 UserGet after user name update", func(t *testing.T) {
 		endpoint := "/v1/user/" + strconv.Itoa(resp.ID)
 		r := e2e.NewRequest(http.MethodGet, endpoint, nil, e2e.WithQuery("typ", "new"))
 		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
 	})
+	s.Run(t)
+}
+
+// TestScenarioDuplicateStep shows that steps of the same name are rejected
+// before any step runs.
+func TestScenarioDuplicateStep(t *testing.T) {
+	e2e.XFail(t, "duplicate step name")
+
+	var ran bool
+	s := e2e.NewScenario()
+	s.Step("1 UserGet", func(t *testing.T) { ran = true })
+	s.Step("1 UserGet", func(t *testing.T) { ran = true })
+	t.Cleanup(func() {
+		if ran {
+			t.Error("a step ran")
+		}
+	})
+	s.Run(t)
+}
+
+// TestUserScenarioBranches shows a scenario whose independent steps run in
+// parallel once the step they depend on passed.
+func TestUserScenarioBranches(t *testing.T) {
+	stands, stats := make(chan struct{}), make(chan struct{})
+	// overlap closes started and waits until the other branch started too,
+	// which never happens when the branches run one after the other.
+	overlap := func(t *testing.T, started, other chan struct{}) {
+		t.Helper()
+
+		close(started)
+		select {
+		case <-other:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the branches did not run in parallel")
+		}
+	}
+
+	s := e2e.NewScenario()
+	s.Step("1 UserGet", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+	})
+	s.Step("2 UserStands", func(t *testing.T) {
+		overlap(t, stands, stats)
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1/stands", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+	}, e2e.DependsOn("1 UserGet"))
+	s.Step("2 UserStats", func(t *testing.T) {
+		overlap(t, stats, stands)
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1/stats", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+	}, e2e.DependsOn("1 UserGet"))
+	s.Run(t)
+}
+
+// TestScenarioFailedDependency shows that the steps depending on a failed
+// step are skipped while the other steps run. The failing scenario runs in a
+// subprocess, so that it does not fail the suite.
+func TestScenarioFailedDependency(t *testing.T) {
+	const run = "^TestScenarioFailedDependency$"
+	if os.Getenv("E2E_SUBPROCESS") == run {
+		s := e2e.NewScenario()
+		s.Step("1 UserPost", func(t *testing.T) {
+			t.Error("the user service is down")
+		})
+		s.Step("2 UserGet", func(t *testing.T) {
+			t.Error("a step depending on a failed step ran")
+		})
+		s.Step("3 independent", func(t *testing.T) {}, e2e.DependsOn())
+		s.Run(t)
+		return
+	}
+
+	out, err := runSuite(t, ".", run, "-test.v")
+	if err == nil {
+		t.Fatalf("the failing scenario passed:\n%s", out)
+	}
+	for _, want := range []string{
+		"--- FAIL: TestScenarioFailedDependency/1_UserPost",
+		"--- SKIP: TestScenarioFailedDependency/2_UserGet",
+		`skipped because step "1 UserPost" failed`,
+		"--- PASS: TestScenarioFailedDependency/3_independent",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestCreate shows removing the resources a test created, through the Runner
+// which created them, e.g. in a shared staging environment.
+func TestCreate(t *testing.T) {
+	var deleted []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	})
+	mux.HandleFunc("DELETE /v1/user/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleted = append(deleted, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		description string
+		rn          *e2e.Runner
+	}{
+		{
+			description: "created",
+			rn:          e2e.New(nil, e2e.Remote(srv.URL)),
+		},
+		{
+			// The response of the shadow handler creates nothing.
+			description: "shadowed",
+			rn:          e2e.New(nil, e2e.Remote(srv.URL), e2e.WithShadow(mux)),
+		},
+		{
+			// The resource is removed even when the filters of the Runner
+			// stop the test.
+			description: "stopped",
+			rn: e2e.New(nil, e2e.Remote(srv.URL), e2e.WithFilters(func(t *testing.T, _ *http.Response) {
+				t.SkipNow()
+			})),
+		},
+	}
+	for _, tt := range tests {
+		n := len(deleted)
+		t.Run(tt.description, func(t *testing.T) {
+			resp := struct{ ID int }{}
+			r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+			cleanup := e2e.DeleteOnCleanup(func() string { return "/v1/user/" + strconv.Itoa(resp.ID) })
+			tt.rn.Create(t, r, http.StatusCreated, cleanup, e2e.CaptureResponse(&resp))
+		})
+		if got := len(deleted) - n; got != 1 {
+			t.Errorf("%s: deleted %d times, want: 1", tt.description, got)
+		}
+	}
+}
+
+// TestXFail shows keeping the regression tests of a known bug merged before
+// the fix lands. The test passes as long as one of its subtests reproduces
+// the bug, and fails once none does, prompting the removal of XFail.
+func TestXFail(t *testing.T) {
+	// PUT /v1/user/1 responds 204 instead of 200 with the updated user.
+	e2e.XFail(t, "JIRA-123")
+
+	t.Run("status", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPut, "/v1/user/1", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+	t.Run("fatal", func(t *testing.T) {
+		// The invalid request ends the subtest, which is skipped.
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1", strings.NewReader(`{"name":"JoJo"}`))
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+}
+
+// TestQuarantine shows a known-broken test listed in testdata/quarantine.txt.
+// Its subtests run but are skipped when they fail, fatally or not, and the
+// suite reports them at the end.
+func TestQuarantine(t *testing.T) {
+	t.Run("status", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPut, "/v1/user/1", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+	t.Run("fatal", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1", strings.NewReader(`{"name":"JoJo"}`))
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+}
+
+// TestExpectHeaderNoContent shows ExpectHeader failing on a 204 response
+// without headers, which does not have the header either.
+func TestExpectHeaderNoContent(t *testing.T) {
+	// PUT /v1/user/1 does not respond the ETag of the updated user.
+	e2e.XFail(t, "JIRA-456")
+
+	r := e2e.NewRequest(http.MethodPut, "/v1/user/1", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+	e2e.RunTest(t, r, http.StatusNoContent, e2e.ExpectHeader("ETag", `"1"`))
+}
+
+// TestServerTimeouts shows a slow client example against a real server.
+func TestServerTimeouts(t *testing.T) {
+	defer func(read, write time.Duration) {
+		readTimeout, writeTimeout = read, write
+	}(readTimeout, writeTimeout)
+	readTimeout, writeTimeout = 100*time.Millisecond, 300*time.Millisecond
+
+	srv := e2e.StartServer(t, e2e.WithServerConfig(configureServer))
+	// The upload and the large download are served by handlers of their own,
+	// so that the timeouts are tested apart from the validation of the routes.
+	const size = 32 << 20
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /upload", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			http.Error(w, "Request timeout", http.StatusRequestTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = w.Write(make([]byte, size))
+	})
+	files := httptest.NewUnstartedServer(mux)
+	configureServer(files.Config)
+	files.Start()
+	t.Cleanup(files.Close)
+
+	t.Run("slow write exceeds read timeout", func(t *testing.T) {
+		c := e2e.NewClient(files, e2e.ThrottleWrite(4, 50*time.Millisecond))
+		r := e2e.NewRequest(http.MethodPost, "/upload", strings.NewReader("Jonathan Joestar"))
+		got, err := c.Do(r)
+		if err == nil && got.StatusCode == http.StatusNoContent {
+			t.Errorf("upload completed although the body took longer than the read timeout")
+		}
+	})
+	t.Run("slow read within write timeout", func(t *testing.T) {
+		c := e2e.NewClient(srv, e2e.ThrottleRead(4, 10*time.Millisecond))
+		r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+		got, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.StatusCode != http.StatusOK {
+			t.Errorf("HTTP StatusCode: %d, want: %d", got.StatusCode, http.StatusOK)
+		}
+		body, err := io.ReadAll(got.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"hoge":"fuga"}`; string(body) != want {
+			t.Errorf("body: %q, want: %q", body, want)
+		}
+	})
+	t.Run("slow read exceeds write timeout", func(t *testing.T) {
+		c := e2e.NewClient(files, e2e.ThrottleRead(256<<10, 10*time.Millisecond))
+		r := e2e.NewRequest(http.MethodGet, "/download", nil)
+		_, err := c.Do(r)
+		if err == nil {
+			t.Fatal("download completed although it took longer than the write timeout")
+		}
+		// The server closes the connection, truncating the response.
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("error: %v, want: %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+// TestConnectionReuse shows a keep-alive example across scenario steps.
+func TestConnectionReuse(t *testing.T) {
+	srv := e2e.StartServer(t)
+
+	tests := []struct {
+		name string
+		opts []e2e.ClientOption
+		want bool
+	}{
+		{
+			name: "keep-alive",
+			want: true,
+		},
+		{
+			name: "new connections",
+			opts: []e2e.ClientOption{e2e.NewConnections()},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace e2e.Trace
+			c := e2e.NewClient(srv, append(tt.opts, e2e.WithTrace(&trace))...)
+			for i := range 2 {
+				if _, err := c.Do(e2e.NewRequest(http.MethodGet, "/v1/health", nil)); err != nil {
+					t.Fatal(err)
+				}
+				if got := trace.Reused; i > 0 && got != tt.want {
+					t.Errorf("connection reused: %t, want: %t", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestHTTP2 shows HTTP/2 and h2c examples against a real server.
+func TestHTTP2(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  e2e.ServerOption
+	}{
+		{
+			name: "h2",
+			opt:  e2e.WithHTTP2(),
+		},
+		{
+			name: "h2c",
+			opt:  e2e.WithH2C(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := e2e.NewClient(e2e.StartServer(t, tt.opt))
+			r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+			c.RunTest(t, r, http.StatusOK, e2e.ExpectProtocol("HTTP/2.0"), e2e.PrettyJSON)
+		})
+	}
+}
+
+// TestHTTP2Streams shows HTTP/2 features of a large streamed download: the
+// trailer sent after the body, and the flow control making the handler wait
+// for the slow client instead of buffering the body.
+func TestHTTP2Streams(t *testing.T) {
+	// Larger than the flow control window of the client.
+	const size = 8 << 20
+	body := make([]byte, size)
+	wrote := make(chan time.Duration, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/users/archive", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		start := time.Now()
+		_, _ = w.Write(body)
+		wrote <- time.Since(start)
+		w.Header().Set("X-Checksum", fmt.Sprintf("%x", sha256.Sum256(body)))
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	// Reading the body takes at least 32 * 5ms.
+	c := e2e.NewClient(srv, e2e.ThrottleRead(256<<10, 5*time.Millisecond))
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/archive", nil)
+	c.RunTest(t, r, http.StatusOK,
+		e2e.ExpectProtocol("HTTP/2.0"),
+		e2e.ExpectTrailer("X-Checksum", fmt.Sprintf("%x", sha256.Sum256(body))),
+		e2e.BinaryDigest(),
+	)
+	// The handler wrote the part beyond the window as the client read it.
+	if d := <-wrote; d < 40*time.Millisecond {
+		t.Errorf("handler wrote %d bytes in %s, before the client read them", size, d)
+	}
+}
+
+// TestExpectContinue shows an example of uploads rejected by their headers.
+func TestExpectContinue(t *testing.T) {
+	srv := e2e.StartServer(t)
+
+	tests := []struct {
+		name     string
+		body     map[string]any
+		want     int
+		wantSent bool
+	}{
+		{
+			name:     "accepted",
+			body:     map[string]any{"name": "JoJo"},
+			want:     http.StatusCreated,
+			wantSent: true,
+		},
+		{
+			name:     "rejected before body",
+			body:     map[string]any{"name": strings.Repeat("JoJo", 1<<10)},
+			want:     http.StatusRequestEntityTooLarge,
+			wantSent: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace e2e.Trace
+			c := e2e.NewClient(srv, e2e.ExpectContinue(time.Second), e2e.WithTrace(&trace), e2e.NewConnections())
+			got, err := c.Do(e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, tt.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.StatusCode != tt.want {
+				t.Errorf("HTTP StatusCode: %d, want: %d", got.StatusCode, tt.want)
+			}
+			if trace.BodySent != tt.wantSent {
+				t.Errorf("body sent: %t, want: %t", trace.BodySent, tt.wantSent)
+			}
+			if gotContinue := len(trace.Interim) > 0 && trace.Interim[0].StatusCode == http.StatusContinue; gotContinue != tt.wantSent {
+				t.Errorf("100 Continue received: %t, want: %t", gotContinue, tt.wantSent)
+			}
+		})
+	}
+}
+
+// TestEarlyHints shows an example of informational responses in golden files.
+func TestEarlyHints(t *testing.T) {
+	c := e2e.NewClient(e2e.StartServer(t), e2e.CaptureInterim())
+	r := e2e.NewRequest(http.MethodGet, "/v1/home", nil)
+	c.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+}
+
+// TestUsersExport shows an example of asserting a streaming endpoint.
+func TestUsersExport(t *testing.T) {
+	var log e2e.ControllerLog
+	c := e2e.NewClient(e2e.StartServer(t, e2e.ObserveController(&log)))
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/export", nil)
+	c.RunTest(t, r, http.StatusOK)
+
+	// Every record is flushed as soon as it is written.
+	want := []int{
+		len(`{"name":"Jonathan Joestar"}` + "\n"),
+		len(`{"name":"Joseph Joestar"}` + "\n"),
+		len(`{"name":"Jotaro Kujo"}` + "\n"),
+	}
+	if diff := cmp.Diff(want, log.Flushes()); diff != "" {
+		t.Errorf("bytes per flush mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := len(log.WriteDeadlines()), 1; got != want {
+		t.Errorf("write deadlines: %d, want: %d", got, want)
+	}
+}
+
+// TestLargeGolden shows a golden file large enough to be mapped into memory
+// instead of read, and compared without copying it.
+func TestLargeGolden(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/users/names", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for i := range 2000 {
+			fmt.Fprintf(w, "user %d\n", i+1)
+		}
+	})
+	// With -count, the later runs compare against the cached mapping.
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/names", nil)
+	e2e.New(mux).RunTest(t, r, http.StatusOK)
+}
+
+// TestUsersExportRecords shows an example of asserting the records of an
+// NDJSON stream and when they were flushed.
+func TestUsersExportRecords(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/export", nil)
+	e2e.RunNDJSON(t, r, http.StatusOK, e2e.TimingBuckets(time.Second))
+}
+
+// TestUserGetEndpointV2 shows an example of responses wrapped in an envelope
+// and with hypermedia links.
+func TestUserGetEndpointV2(t *testing.T) {
+	var user struct {
+		ID    int            `json:"id"`
+		Name  string         `json:"name"`
+		Links map[string]any `json:"_links"`
+	}
+	r := e2e.NewRequest(http.MethodGet, "/v2/user/1", nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.CaptureResponse(&user, e2e.DisallowUnknownFields()), e2e.ValidateLinks(e2e.GoldenLinks()), e2e.PrettyJSON)
+
+	if user.Name != "JoJo" {
+		t.Errorf("name: %q, want: %q", user.Name, "JoJo")
+	}
+}
+
+// TestCrawl shows a smoke test example following the links of the service.
+func TestCrawl(t *testing.T) {
+	e2e.Crawl(t, []string{"/v1/health", "/v2/health", "/v2/user/1"})
+}
+
+// TestContentNegotiation shows an example of hardening content negotiation.
+func TestContentNegotiation(t *testing.T) {
+	e2e.CheckNegotiation(t, func(t *testing.T) *http.Request {
+		return e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+	})
+}
+
+// TestUserPostCorpus shows an example of hardening string and numeric fields.
+func TestUserPostCorpus(t *testing.T) {
+	template := map[string]any{"name": "JoJo", "age": 17}
+	e2e.CheckStrings(t, http.MethodPost, "/v1/user", template)
+	e2e.CheckNumbers(t, http.MethodPost, "/v1/user", template, nil)
+}
+
+// TestUserProfileCorpus shows an example of hardening the fields of an
+// endpoint which only accepts JSON requests. The corpus requests must reach
+// the handler instead of being rejected for their content type.
+func TestUserProfileCorpus(t *testing.T) {
+	template := map[string]any{"name": "JoJo", "age": 17}
+
+	t.Run("strings", func(t *testing.T) {
+		var accepted int
+		rn := e2e.New(nil, e2e.WithMiddleware(countAccepted(&accepted)))
+		// The accepted responses echo the name, which is compared with the
+		// one sent.
+		rn.CheckStrings(t, http.MethodPost, "/v1/user/profile", template)
+		if accepted == 0 {
+			t.Errorf("no string corpus request was accepted")
+		}
+	})
+	t.Run("numbers", func(t *testing.T) {
+		var accepted int
+		rn := e2e.New(nil, e2e.WithMiddleware(countAccepted(&accepted)))
+		rn.CheckNumbers(t, http.MethodPost, "/v1/user/profile", template, nil)
+		if accepted == 0 {
+			t.Errorf("no numeric corpus request was accepted")
+		}
+	})
+}
+
+// countAccepted returns middleware counting the 2xx responses of the
+// handler in n.
+func countAccepted(n *int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code < http.StatusMultipleChoices {
+				*n++
+			}
+			maps.Copy(w.Header(), rec.Header())
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(rec.Body.Bytes())
+		})
+	}
+}
+
+// TestUserPostMalformedJSON shows an example of asserting controlled rejection.
+func TestUserPostMalformedJSON(t *testing.T) {
+	e2e.CheckMalformedJSON(t, http.MethodPost, "/v1/user")
+}
+
+func TestConsumers(t *testing.T) {
+	e2e.VerifyConsumers(t, "testdata/consumers")
+}
+
+// userSDK stands in for a client SDK generated from the API spec.
+type userSDK struct {
+	baseURL string
+	client  *http.Client
+}
+
+type sdkUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (c *userSDK) GetUser(id int) (*sdkUser, error) {
+	resp, err := c.client.Get(c.baseURL + "/v2/user/" + strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GetUser: unexpected status %d", resp.StatusCode)
+	}
+	var v struct {
+		Data sdkUser `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, err
+	}
+	return &v.Data, nil
+}
+
+// TestUserSDK shows an SDK round-trip example.
+func TestUserSDK(t *testing.T) {
+	e2e.RunSDK(t, func(c *http.Client) (*sdkUser, error) {
+		sdk := &userSDK{baseURL: "http://api.example.com", client: c}
+		return sdk.GetUser(1)
+	}, e2e.PrettyJSON)
+}
+
+// TestMockIndex checks that the mock index is up to date: a fresh -golden
+// run of the suite on a copy of the package indexes the same golden files.
+func TestMockIndex(t *testing.T) {
+	skipInSubprocess(t)
+
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(".")); err != nil {
+		t.Fatal(err)
+	}
+	// Runs of all the tests replace the index, as if built from scratch.
+	if out, err := runSuite(t, dir, "", "-golden"); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	want, err := os.ReadFile(filepath.Join(dir, "testdata", "mock.index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join("testdata", "mock.index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("testdata/mock.index is stale; run the suite with -golden (-want +got):\n%s", diff)
+	}
+}
+
+// TestMockHandler shows serving the goldens as a mock.
+func TestMockHandler(t *testing.T) {
+	h, err := e2e.MockHandler("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user/1?typ=exception", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status: %d, want: %d", w.Code, http.StatusInternalServerError)
+	}
+	if got, want := w.Body.String(), "Server error\n"; got != want {
+		t.Errorf("body: %q, want: %q", got, want)
+	}
+}
+
+// TestShadow shows comparing the router with a rewrite of it.
+func TestShadow(t *testing.T) {
+	e2e.RegisterShadow(newRouter())
+	t.Cleanup(func() { e2e.RegisterShadow(nil) })
+
+	r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+	e2e.RunTest(t, r, http.StatusCreated, e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+}
+
+// TestRunner shows testing two router configurations side by side.
+func TestRunner(t *testing.T) {
+	tests := []struct {
+		name    string
+		runner  *e2e.Runner
+		filters []e2e.ResponseFilter
+	}{
+		{
+			name:    "raw",
+			runner:  e2e.New(newRouter()),
+			filters: []e2e.ResponseFilter{e2e.ModifyJSON(map[string]any{"meta": map[string]any{"request_id": "0"}})},
+		},
+		{
+			name: "enveloped",
+			runner: e2e.New(newRouter(), e2e.WithEnvelope(e2e.Envelope{
+				Data: "data",
+				Meta: map[string]any{"meta": map[string]any{"request_id": "normalized"}},
+			})),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := e2e.NewRequest(http.MethodGet, "/v2/user/1", nil)
+			tt.runner.RunTest(t, r, http.StatusOK, append(tt.filters, e2e.PrettyJSON)...)
+		})
+	}
+}
+
+// TestUserScenarioVariables shows passing values between steps through
+// scenario variables.
+func TestUserScenarioVariables(t *testing.T) {
+	s := e2e.NewScenario()
+	s.Step("1 UserPost registration", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+		e2e.RunTest(t, r, http.StatusCreated, s.Capture("id", "id"), e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+	})
+	s.Step("2 UserGet after registration", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, s.Expand(t, "/v1/user/{id}"), nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+	})
+	s.Step("3 UserPut from fixture", func(t *testing.T) {
+		body := s.TemplateBody(t, "testdata/requests/update_user.tmpl.json", map[string]any{"name": "Giorno Giovanna"})
+		r := e2e.NewRequest(http.MethodPut, s.Expand(t, "/v1/user/{id}"), body)
+		e2e.RunTest(t, r, http.StatusNoContent)
+	})
+	s.Run(t)
+}
+
+// TestUserScenarioProtocols shows a scenario mixing protocols: the user
+// registered over HTTP is read over gRPC, whose response feeds the next HTTP
+// step.
+func TestUserScenarioProtocols(t *testing.T) {
+	e2egrpc.RegisterServer(registerGRPC)
+
+	s := e2e.NewScenario()
+	s.Step("1 UserPost registration", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+		e2e.RunTest(t, r, http.StatusCreated, s.Capture("id", "id"), e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+	})
+	s.Step("2 GetUser over gRPC", func(t *testing.T) {
+		id, err := strconv.ParseInt(s.Expand(t, "{id}"), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e2egrpc.RunTest(t, "/example.v1.UserService/GetUser", wrapperspb.Int64(id), new(structpb.Struct), codes.OK, e2egrpc.Capture(s, "name", "name"))
+	})
+	s.Step("3 UserPut same name", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPut, s.Expand(t, "/v1/user/{id}"), e2e.JSONBody(t, map[string]any{"name": s.Get("name")}))
+		e2e.RunTest(t, r, http.StatusNoContent)
+	})
+	s.Run(t)
+}
+
+// TestNamedRouters shows addressing several services from one test.
+func TestNamedRouters(t *testing.T) {
+	admin := http.NewServeMux()
+	admin.HandleFunc("GET /admin/users/count", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"count":1}`))
+	})
+	e2e.RegisterRouterNamed("admin", admin)
+
+	t.Run("gateway", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+	t.Run("admin", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/admin/users/count", nil, e2e.Target("admin"))
+		e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+	})
+}
+
+// registerRouterFactory registers a factory of routers numbered in build
+// order according to policy until t completes, returning the lifecycle
+// events of the routers.
+func registerRouterFactory(t *testing.T, policy e2e.RouterPolicy) *[]string {
+	var events []string
+	t.Cleanup(func() {
+		e2e.RegisterRouterHooks(e2e.RouterHooks{})
+		e2e.RegisterRouter(newRouter())
+	})
+
+	routers := make(map[http.Handler]int)
+	e2e.RegisterRouterFactory(func() (http.Handler, func()) {
+		h := newRouter()
+		n := len(routers) + 1
+		routers[h] = n
+		return h, func() { events = append(events, fmt.Sprintf("release %d", n)) }
+	}, policy)
+	e2e.RegisterRouterHooks(e2e.RouterHooks{
+		Build: func(t *testing.T, h http.Handler) {
+			events = append(events, fmt.Sprintf("build %d for %s", routers[h], t.Name()))
+		},
+		Use: func(t *testing.T, h http.Handler) {
+			events = append(events, fmt.Sprintf("use %d by %s", routers[h], t.Name()))
+		},
+	})
+	return &events
+}
+
+// TestRouterPerSubtest shows isolating the subtests with a router each.
+func TestRouterPerSubtest(t *testing.T) {
+	events := registerRouterFactory(t, e2e.RouterPerSubtest)
+
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			// Both calls are served by the router of the subtest.
+			e2e.CheckDeterministic(t, e2e.NewRequest(http.MethodGet, "/v1/health", nil), 2)
+			e2e.CheckDeterministic(t, e2e.NewRequest(http.MethodGet, "/v1/user/1", nil), 2)
+		})
+	}
+	want := []string{
+		"build 1 for TestRouterPerSubtest/a", "use 1 by TestRouterPerSubtest/a", "release 1",
+		"build 2 for TestRouterPerSubtest/b", "use 2 by TestRouterPerSubtest/b", "release 2",
+	}
+	if diff := cmp.Diff(want, *events); diff != "" {
+		t.Errorf("router lifecycle mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestRouterPerTest shows sharing a router between the subtests of a test,
+// which is released when the test completes.
+func TestRouterPerTest(t *testing.T) {
+	events := registerRouterFactory(t, e2e.RouterPerTest)
+	t.Cleanup(func() {
+		want := []string{
+			"build 1 for TestRouterPerTest/a", "use 1 by TestRouterPerTest/a",
+			"use 1 by TestRouterPerTest/b", "use 1 by TestRouterPerTest", "release 1",
+		}
+		if diff := cmp.Diff(want, *events); diff != "" {
+			t.Errorf("router lifecycle mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			e2e.CheckDeterministic(t, e2e.NewRequest(http.MethodGet, "/v1/health", nil), 2)
+		})
+	}
+	e2e.CheckDeterministic(t, e2e.NewRequest(http.MethodGet, "/v1/user/1", nil), 2)
+}
+
+// TestRemote shows reusing golden files against a live server, such as a
+// staging environment.
+func TestRemote(t *testing.T) {
+	srv := e2e.StartServer(t)
+	rn := e2e.New(nil, e2e.Remote(srv.URL, e2e.RemoteTimeout(5*time.Second)))
+
+	r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+	rn.RunTest(t, r, http.StatusOK)
+}
+
+// TestRemoteHelpers shows the helpers of a Runner sending their requests to
+// a live server like RunTest does.
+func TestRemoteHelpers(t *testing.T) {
+	srv := e2e.StartServer(t)
+	rn := e2e.New(nil, e2e.Remote(srv.URL, e2e.RemoteTimeout(5*time.Second)))
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		rn.CheckMalformedJSON(t, http.MethodPost, "/v1/user")
+	})
+	t.Run("deterministic", func(t *testing.T) {
+		rn.CheckDeterministic(t, e2e.NewRequest(http.MethodGet, "/v1/user/1", nil), 3)
+	})
+	t.Run("events", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/users/events", nil)
+		rn.RunSSE(t, r, http.StatusOK, e2e.MaxEvents(3))
+	})
+	t.Run("records", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/users/export", nil)
+		rn.RunNDJSON(t, r, http.StatusOK)
+	})
+}
+
+// TestEventually shows waiting for time-based behavior without sleeping.
+func TestEventually(t *testing.T) {
+	clock := e2e.NewFakeClock(time.Date(2023, time.February, 23, 0, 0, 0, 0, time.UTC))
+	started := clock.Now()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		status := "pending"
+		if clock.Since(started) >= time.Minute {
+			status = "done"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status})
+	})
+
+	e2e.Eventually(t, clock, 5*time.Minute, 10*time.Second, func() bool {
+		var resp struct{ Status string }
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, e2e.NewRequest(http.MethodGet, "/v1/users/export/status", nil))
+		return json.Unmarshal(w.Body.Bytes(), &resp) == nil && resp.Status == "done"
+	})
+	e2e.New(h).RunTest(t, e2e.NewRequest(http.MethodGet, "/v1/users/export/status", nil), http.StatusOK)
+}
+
+// TestProfileSlow shows profiling slow requests.
+func TestProfileSlow(t *testing.T) {
+	dir := t.TempDir()
+	rn := e2e.New(newRouter(), e2e.ProfileSlow(time.Nanosecond, dir))
+
+	r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+	rn.RunTest(t, r, http.StatusOK)
+	if _, err := os.Stat(filepath.Join(dir, t.Name()+".heap.pprof")); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestOverSocket shows testing a handler depending on a real connection.
+func TestOverSocket(t *testing.T) {
+	ip := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := strings.Cut(r.RemoteAddr, ":")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(host))
+	})
+	rn := e2e.New(ip, e2e.OverSocket())
+
+	r := e2e.NewRequest(http.MethodGet, "/ip", nil)
+	rn.RunTest(t, r, http.StatusOK)
+}
+
+// TestUserPostBarrage shows hunting data races with concurrent requests. Run
+// it with -race.
+func TestUserPostBarrage(t *testing.T) {
+	e2e.Barrage(t, func(i int) *http.Request {
+		return e2e.NewRequest(http.MethodPost, "/v1/user", strings.NewReader(`{"name":"JoJo`+strconv.Itoa(i)+`"}`))
+	}, e2e.Goroutines(4), e2e.Iterations(20))
+}
+
+// TestGRPCGetUser shows a gRPC golden testing example.
+func TestGRPCGetUser(t *testing.T) {
+	e2egrpc.RegisterServer(registerGRPC)
+
+	tests := []struct {
+		name string
+		id   int64
+		want codes.Code
+	}{
+		{name: "found", id: 1, want: codes.OK},
+		{name: "not found", id: 2, want: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e2egrpc.RunTest(t, "/example.v1.UserService/GetUser", wrapperspb.Int64(tt.id), new(structpb.Struct), tt.want)
+		})
+	}
+}
+
+// TestGRPCErrorDetails shows a gRPC example of asserting the metadata and
+// the error details of a status, with the request ID normalized.
+func TestGRPCErrorDetails(t *testing.T) {
+	e2egrpc.RegisterServer(registerGRPC)
+
+	e2egrpc.RunTest(t, "/example.v1.UserService/GetUser", wrapperspb.Int64(0), new(structpb.Struct), codes.InvalidArgument,
+		e2egrpc.ExpectTrailer("content-type", "application/grpc"),
+		e2egrpc.MaskMetadata("x-request-id"),
+		e2egrpc.ModifyDetails(map[string]any{"requestId": "0"}),
+		e2egrpc.ExpectDetail(&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "value", Description: "must be positive"},
+		}}),
+		e2egrpc.ExpectDetail(&errdetails.RequestInfo{RequestId: "0"}),
+	)
+}
+
+// TestGRPCStream shows a gRPC golden testing example of streaming methods,
+// whose messages are normalized one by one.
+func TestGRPCStream(t *testing.T) {
+	e2egrpc.RegisterServer(registerGRPC)
+
+	t.Run("server streaming", func(t *testing.T) {
+		e2egrpc.RunStream(t, "/example.v1.UserService/ListUsers", []proto.Message{new(emptypb.Empty)}, new(structpb.Struct), codes.Unavailable,
+			e2egrpc.ModifyMessages(map[string]any{"sent_at": "2023-02-23T07:15:20Z"}))
+	})
+	t.Run("bidi streaming", func(t *testing.T) {
+		reqs := []proto.Message{wrapperspb.String("Dio"), wrapperspb.String("Vanilla Ice")}
+		e2egrpc.RunStream(t, "/example.v1.UserService/Chat", reqs, new(wrapperspb.StringValue), codes.OK)
+	})
+}
+
+// TestUserGetDeterministic shows checking that normalized responses are
+// stable before recording golden files.
+func TestUserGetDeterministic(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v2/user/1", nil)
+	e2e.CheckDeterministic(t, r, 5)
+}
+
+// TestWebSocket shows a WebSocket scenario example.
+func TestWebSocket(t *testing.T) {
+	e2ews.RunTest(t, "/v1/ws", []e2ews.Step{
+		{Send: "hello", Receive: 1},
+		{Send: "world", Receive: 1},
+		{Send: "bye", Receive: 1},
+	})
+}
+
+// TestMiddleware shows injecting test-only middleware.
+func TestMiddleware(t *testing.T) {
+	unavailable := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Inject-Fault") != "" {
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	rn := e2e.New(newRouter(), e2e.WithMiddleware(unavailable))
+
+	r := e2e.NewRequest(http.MethodGet, "/v1/health", nil, e2e.WithHeader("X-Inject-Fault", "1"))
+	rn.RunTest(t, r, http.StatusServiceUnavailable)
+}
+
+// TestUserEvents shows a server-sent events example.
+func TestUserEvents(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/events", nil)
+	e2e.RunSSE(t, r, http.StatusOK, e2e.MaxEvents(3))
+}
+
+// TestGraphQL shows a GraphQL example.
+func TestGraphQL(t *testing.T) {
+	graphql := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Variables["id"] != float64(1) {
+			_, _ = w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"user not found","locations":[{"line":1,"column":20}],"path":["user"]}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"user":{"name":"JoJo"}}}`))
+	})
+	rn := e2e.New(graphql)
+	const query = `query($id: ID!) { user(id: $id) { name } }`
+
+	t.Run("found", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/graphql", e2e.GraphQLBody(t, query, map[string]any{"id": 1}))
+		rn.RunTest(t, r, http.StatusOK, e2e.NoGraphQLErrors, e2e.PrettyJSON)
+	})
+	t.Run("not found", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/graphql", e2e.GraphQLBody(t, query, map[string]any{"id": 2}))
+		rn.RunTest(t, r, http.StatusOK, e2e.MaskGraphQLErrors(), e2e.PrettyJSON)
+	})
+}
+
+// TestSessionScenario shows a session carried over scenario steps.
+func TestSessionScenario(t *testing.T) {
+	s := e2e.NewScenario()
+	s.Step("1 Login", func(t *testing.T) {
+		r := s.NewRequest(http.MethodPost, "/v1/login", nil)
+		e2e.RunTest(t, r, http.StatusNoContent)
+	})
+	s.Step("2 Me with session", func(t *testing.T) {
+		r := s.NewRequest(http.MethodGet, "/v1/me", nil)
+		e2e.RunTest(t, r, http.StatusOK)
+	})
+	s.Run(t)
+}
+
+// TestMeWithCookie shows an example of requests with cookies.
+func TestMeWithCookie(t *testing.T) {
+	tests := []struct {
+		description []string
+		cookies     []*http.Cookie
+		want        int
+	}{
+		{
+			description: []string{"session"},
+			cookies:     []*http.Cookie{{Name: "session", Value: "jojo"}, {Name: "theme", Value: "dark"}},
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"no session"},
+			want:        http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName("/v1/me", tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, "/v1/me", nil, e2e.WithCookies(tt.cookies...))
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestAdminAuth shows an example of authenticated requests.
+func TestAdminAuth(t *testing.T) {
+	const endpoint = "/v1/admin"
+
+	tests := []struct {
+		description []string
+		option      e2e.RequestOption
+		want        int
+	}{
+		{
+			description: []string{"basic"},
+			option:      e2e.WithBasicAuth("dio", "za warudo"),
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"bearer"},
+			option:      e2e.WithBearerToken("dio-token"),
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"wrong password"},
+			option:      e2e.WithBasicAuth("dio", "muda"),
+			want:        http.StatusUnauthorized,
+		},
+	}
+	// The cases must not depend on each other.
+	for _, tt := range e2e.Shuffle(t, tests) {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, tt.option)
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestUserAvatar shows an example of file uploads.
+func TestUserAvatar(t *testing.T) {
+	const endpoint = "/v1/user/avatar"
+
+	tests := []struct {
+		description []string
+		file        e2e.MultipartFile
+		want        int
+	}{
+		{
+			description: []string{"from path"},
+			file:        e2e.MultipartFile{Field: "avatar", Path: "testdata/upload/avatar.svg", ContentType: "image/svg+xml"},
+			want:        http.StatusCreated,
+		},
+		{
+			description: []string{"from content"},
+			file:        e2e.MultipartFile{Field: "avatar", Filename: "avatar.txt", Content: []byte("ORA ORA ORA")},
+			want:        http.StatusCreated,
+		},
+		{
+			description: []string{"without file"},
+			file:        e2e.MultipartFile{Field: "icon", Filename: "icon.txt", Content: []byte("MUDA")},
+			want:        http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			body := e2e.MultipartBody(t, map[string]string{"name": "JoJo"}, tt.file)
+			r := e2e.NewRequest(http.MethodPost, endpoint, body)
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestGreeting shows an example of responses in legacy encodings.
+func TestGreeting(t *testing.T) {
+	const endpoint = "/v1/greeting"
+
+	tests := []struct {
+		description []string
+		lang        string
+	}{
+		{description: []string{"shift_jis"}, lang: "ja"},
+		{description: []string{"iso-8859-1"}, lang: "fr"},
+		{description: []string{"utf-8"}, lang: "en"},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, e2e.WithQuery("lang", tt.lang))
+			e2e.RunTest(t, r, http.StatusOK, e2e.TranscodeCharset)
+		})
+	}
+}
+
+// TestContact shows an example of form posts.
+func TestContact(t *testing.T) {
+	const endpoint = "/v1/contact"
+
+	tests := []struct {
+		description []string
+		form        url.Values
+		want        int
+	}{
+		{
+			description: []string{"success"},
+			form:        url.Values{"name": {"JoJo"}, "topic": {"stand", "hamon"}},
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"without name"},
+			form:        url.Values{"topic": {"stand"}},
+			want:        http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodPost, endpoint, e2e.FormBody(t, tt.form))
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestCacheContract shows an example of caching headers checked for every
+// request of a Runner.
+func TestCacheContract(t *testing.T) {
+	rn := e2e.New(newRouter(), e2e.WithFilters(e2e.CacheContract(e2e.CachePolicy{
+		"GET /static/":  e2e.CacheStatic,
+		"GET /v1/admin": e2e.CacheDynamic,
+	})))
+
+	tests := []struct {
+		description []string
+		endpoint    string
+		option      e2e.RequestOption
+	}{
+		{
+			description: []string{"static"},
+			endpoint:    "/static/logo.svg",
+		},
+		{
+			description: []string{"dynamic"},
+			endpoint:    "/v1/admin",
+			option:      e2e.WithBearerToken("dio-token"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(tt.endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			var opts []e2e.RequestOption
+			if tt.option != nil {
+				opts = append(opts, tt.option)
+			}
+			r := e2e.NewRequest(http.MethodGet, tt.endpoint, nil, opts...)
+			rn.RunTest(t, r, http.StatusOK)
+		})
+	}
+}
+
+// TestSOAPUser shows an example of XML endpoints.
+func TestSOAPUser(t *testing.T) {
+	type getUserRequest struct {
+		XMLName struct{} `xml:"GetUserRequest"`
+		ID      int      `xml:"ID"`
+	}
+
+	r := e2e.NewRequest(http.MethodPost, "/v1/soap/user", e2e.XMLBody(t, getUserRequest{ID: 1}))
+	e2e.RunTest(t, r, http.StatusOK, e2e.PrettyXML)
+}
+
+// TestExportSite shows an example of the golden files rendered as a site.
+func TestExportSite(t *testing.T) {
+	out := t.TempDir()
+	if err := e2e.ExportSite("testdata", out); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(out, "GET_v1_user_1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "500 Internal Server Error") {
+		t.Errorf("page of GET /v1/user/1 lacks the 500 response:\n%s", page)
+	}
+}
+
+// TestUserProto shows an example of protobuf bodies.
+func TestUserProto(t *testing.T) {
+	const endpoint = "/v1/user/proto"
+
+	tests := []struct {
+		description []string
+		filter      e2e.ResponseFilter
+	}{
+		{
+			description: []string{"registered type"},
+			filter:      e2eproto.Pretty(nil),
+		},
+		{
+			description: []string{"given type"},
+			filter:      e2eproto.Pretty(&structpb.Struct{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodPost, endpoint, e2eproto.Body(t, wrapperspb.Int64(1)))
+			e2e.RunTest(t, r, http.StatusOK, tt.filter)
+		})
+	}
+}
+
+// TestUsersPagination shows an example of Link header pagination.
+func TestUsersPagination(t *testing.T) {
+	const endpoint = "/v1/users"
+
+	tests := []struct {
+		description []string
+		cursor      string
+	}{
+		{description: []string{"first page"}},
+		{description: []string{"middle page"}, cursor: base64.RawURLEncoding.EncodeToString([]byte("2:0"))},
+		{description: []string{"last page"}, cursor: base64.RawURLEncoding.EncodeToString([]byte("4:0"))},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			var opts []e2e.RequestOption
+			if tt.cursor != "" {
+				opts = append(opts, e2e.WithQuery("cursor", tt.cursor))
+			}
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, opts...)
+			e2e.RunTest(t, r, http.StatusOK, e2e.PaginationLinks(e2e.RequireRels("first")))
+		})
+	}
+}
+
+// TestUserPostFromFile shows an example of request bodies in fixture files.
+func TestUserPostFromFile(t *testing.T) {
+	const endpoint = "/v1/user"
+
+	tests := []struct {
+		description []string
+		body        func(t *testing.T) io.Reader
+	}{
+		{
+			description: []string{"fixture"},
+			body: func(t *testing.T) io.Reader {
+				return e2e.BodyFromFile(t, "testdata/requests/create_user.json")
+			},
+		},
+		{
+			description: []string{"template"},
+			body: func(t *testing.T) io.Reader {
+				return e2e.BodyFromFile(t, "testdata/requests/create_user.tmpl.json", e2e.TemplateData(map[string]any{"Name": "Joseph Joestar", "Age": 18}))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusCreated, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodPost, endpoint, tt.body(t))
+			e2e.RunTest(t, r, http.StatusCreated, e2e.ModifyJSON(map[string]any{"created_time": 1677136520}), e2e.PrettyJSON)
+		})
+	}
+}
+
+// TestUserPostWhatIf shows an example of exploring validation behavior with
+// mutations of a request body.
+func TestUserPostWhatIf(t *testing.T) {
+	base := map[string]any{"name": "Jonathan Joestar", "age": 20}
+	mutations := []e2e.Mutation{
+		e2e.SetField("name", 1),
+		e2e.SetField("age", "twenty"),
+		e2e.SetField("name", ""),
+		e2e.DeleteField("age"),
+	}
+	created := func(t *testing.T, r *http.Response) {
+		if r.StatusCode == http.StatusCreated {
+			e2e.ModifyJSON(map[string]any{"created_time": 1677136520})(t, r)
+		}
+	}
+	e2e.WhatIf(t, http.MethodPost, "/v1/user", base, mutations, created)
+}
+
+type tenantKey struct{}
+
+// TestWithContext shows an example of requests carrying context values read
+// by middleware.
+func TestWithContext(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := r.Context().Value(tenantKey{}).(string)
+		if !ok {
+			http.Error(w, "Tenant required", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"tenant":%q}`, tenant)
+	})
+	rn := e2e.New(h)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "speedwagon")
+	r := e2e.NewRequest(http.MethodGet, "/v1/tenant", nil, e2e.WithContext(ctx))
+	rn.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+}
+
+// TestWhoami shows an example of requests to a virtual host from an IP.
+func TestWhoami(t *testing.T) {
+	const endpoint = "/v1/whoami"
+
+	tests := []struct {
+		description []string
+		remoteAddr  string
+		want        int
+	}{
+		{
+			description: []string{"internal"},
+			remoteAddr:  "10.0.0.1:1234",
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"external"},
+			remoteAddr:  "203.0.113.1:1234",
+			want:        http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, e2e.WithHost("jojo.example.com"), e2e.WithRemoteAddr(tt.remoteAddr))
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestHeaderOrder shows an example of the values of repeated headers, which
+// are sorted unless their order is preserved.
+func TestHeaderOrder(t *testing.T) {
+	rn := e2e.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Add("Via", "1.1 edge")
+		w.Header().Add("Via", "1.1 cache")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// The order of proxies in Via is significant.
+	r := e2e.NewRequest(http.MethodGet, "/v1/order", nil, e2e.PreserveHeaderOrder("via"))
+	rn.RunTest(t, r, http.StatusNoContent)
+}
+
+// TestLogoNotModified shows an example of a conditional request, whose 304
+// response is checked to have no body and is skipped by PrettyXML.
+func TestLogoNotModified(t *testing.T) {
+	const endpoint = "/static/logo.svg"
+
+	tests := []struct {
+		description []string
+		etag        string
+		want        int
+	}{
+		{
+			description: []string{"stale"},
+			etag:        `"logo-v0"`,
+			want:        http.StatusOK,
+		},
+		{
+			description: []string{"fresh"},
+			etag:        `"logo-v1"`,
+			want:        http.StatusNotModified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, e2e.WithHeader("If-None-Match", tt.etag))
+			e2e.RunTest(t, r, tt.want, e2e.PrettyXML)
+		})
+	}
+}
+
+// TestUserStandsEncoding shows an example of a compressed response, which is
+// decoded before the filters so that the golden file is readable.
+func TestUserStandsEncoding(t *testing.T) {
+	const endpoint = "/v1/user/1/stands"
+
+	tests := []struct {
+		description    []string
+		acceptEncoding string
+	}{
+		{
+			description:    []string{"gzip"},
+			acceptEncoding: "gzip",
+		},
+		{
+			description:    []string{"identity"},
+			acceptEncoding: "identity",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, e2e.WithHeader("Accept-Encoding", tt.acceptEncoding))
+			e2e.RunTest(t, r, http.StatusOK, e2e.PrettyJSON)
+		})
+	}
+}
+
+// TestUserFriendsMask shows an example of volatile fields at any depth masked
+// with JSONPath selectors.
+func TestUserFriendsMask(t *testing.T) {
+	const endpoint = "/v1/user/1/friends"
+
+	tests := []struct {
+		description []string
+		filters     []e2e.ResponseFilter
+	}{
+		{
+			description: []string{"wildcard"},
+			filters: []e2e.ResponseFilter{
+				e2e.Mask("$.items[*].fetched_at", 0),
+				e2e.Mask("$.trace.*", "***"),
+			},
+		},
+		{
+			description: []string{"recursive descent"},
+			filters: []e2e.ResponseFilter{
+				e2e.Mask("$..fetched_at", 0),
+				e2e.Mask("$..['id']", "***"),
+				e2e.Mask("$.items[1:].user.name", "(hidden)"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil)
+			e2e.RunTest(t, r, http.StatusOK, append(tt.filters, e2e.PrettyJSON)...)
+		})
+	}
+}
+
+// TestUserReceipt shows an example of redacting volatile substrings of a
+// body which is not JSON.
+func TestUserReceipt(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/user/1/receipt", nil)
+	e2e.RunTest(t, r, http.StatusOK,
+		e2e.RedactPattern(regexp.MustCompile(`\d{4}-\d{2}-\d{2}T[\d:]+Z`), "2023-02-23T07:15:20Z"),
+		e2e.RedactPattern(regexp.MustCompile(`(tok)_[0-9a-f]+`), "${1}_REDACTED"),
+		e2e.RedactPattern(regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`), "00000000-0000-0000-0000-000000000000"),
+	)
+}
+
+// TestFamily shows an example of endpoint families whose expectations are
+// inherited by their endpoints, and overridden by the odd ones.
+func TestFamily(t *testing.T) {
+	rn := e2e.New(newRouter(),
+		e2e.WithFamily("/v1/", e2e.Family{
+			Header:  http.Header{"Content-Type": {"application/json"}},
+			Filters: []e2e.ResponseFilter{e2e.ExpectProtocol("HTTP/1.1")},
+		}),
+		e2e.WithFamily("GET /v1/admin", e2e.Family{
+			Header: http.Header{"Cache-Control": {"no-store"}},
+		}),
+		// Less specific than GET /v1/admin despite the longer pattern.
+		e2e.WithFamily("/v1/{resource}", e2e.Family{
+			Header: http.Header{"Cache-Control": {}},
+		}),
+	)
+
+	tests := []struct {
+		description []string
+		endpoint    string
+		options     []e2e.RequestOption
+		filters     []e2e.ResponseFilter
+	}{
+		{
+			description: []string{"inherited"},
+			endpoint:    "/v1/health",
+			filters:     []e2e.ResponseFilter{e2e.PrettyJSON},
+		},
+		{
+			description: []string{"nested"},
+			endpoint:    "/v1/admin",
+			options:     []e2e.RequestOption{e2e.WithBearerToken("dio-token")},
+			filters:     []e2e.ResponseFilter{e2e.PrettyJSON},
+		},
+		{
+			description: []string{"overridden"},
+			endpoint:    "/v1/greeting",
+			options:     []e2e.RequestOption{e2e.OverrideHeader("Content-Type", "text/plain; charset=utf-8")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(tt.endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, tt.endpoint, nil, tt.options...)
+			rn.RunTest(t, r, http.StatusOK, tt.filters...)
+		})
+	}
+}
+
+// TestUserReceiptPlaceholders shows an example of a golden file written with
+// placeholders matching the volatile values instead of redacting them.
+func TestUserReceiptPlaceholders(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/user/1/receipt", nil)
+	e2e.RunTest(t, r, http.StatusOK)
+}
+
+// TestGoldenByRequest shows an example of golden files named after the
+// requests, which survive renaming the test.
+func TestGoldenByRequest(t *testing.T) {
+	const endpoint = "/v1/user/1"
+
+	tests := []struct {
+		description []string
+		typ         string
+		want        int
+	}{
+		{want: http.StatusOK},
+		{description: []string{"new"}, typ: "new", want: http.StatusOK},
+		{typ: "exception", want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			var opts []e2e.RequestOption
+			if tt.typ != "" {
+				opts = append(opts, e2e.WithQuery("typ", tt.typ))
+			}
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, append(opts, e2e.GoldenByRequest(tt.description...))...)
+			e2e.RunTest(t, r, tt.want)
+		})
+	}
+}
+
+// TestGoldenCollisions shows that golden files shared by tests are reported,
+// as one would overwrite or be compared against the response of the other.
+// The colliding tests run in a subprocess, so that they do not fail the
+// suite.
+func TestGoldenCollisions(t *testing.T) {
+	const run = "^TestGoldenCollisions$"
+	if os.Getenv("E2E_SUBPROCESS") == run {
+		rn := e2e.New(nil, e2e.WithGoldenByRequest())
+		for _, name := range []string{"first", "second"} {
+			t.Run(name, func(t *testing.T) {
+				rn.RunTest(t, e2e.NewRequest(http.MethodGet, "/v1/user/1", nil), http.StatusOK)
+			})
+		}
+		t.Run("twice", func(t *testing.T) {
+			for range 2 {
+				r := e2e.NewRequest(http.MethodGet, "/v1/user/1", nil, e2e.GoldenByRequest("twice"))
+				rn.RunTest(t, r, http.StatusOK)
+			}
+		})
+		for _, description := range []string{"JoJo", "jojo"} {
+			t.Run(description, func(t *testing.T) {
+				r := e2e.NewRequest(http.MethodGet, "/v1/user/1", nil, e2e.GoldenByRequest(description))
+				rn.RunTest(t, r, http.StatusOK)
+			})
+		}
+		return
+	}
+
+	t.Run("collisions", func(t *testing.T) {
+		out, err := runSuite(t, t.TempDir(), run, "-golden")
+		if err == nil {
+			t.Fatalf("colliding tests passed:\n%s", out)
+		}
+		for _, want := range []string{
+			"golden file testdata/GET_v1_user_1_200.golden is used by both TestGoldenCollisions/first and TestGoldenCollisions/second",
+			"golden file testdata/GET_v1_user_1_200_twice.golden is used twice by TestGoldenCollisions/twice",
+			"golden files testdata/GET_v1_user_1_200_JoJo.golden, testdata/GET_v1_user_1_200_jojo.golden differ only in case (TestGoldenCollisions/JoJo, TestGoldenCollisions/jojo)",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("output does not contain %q:\n%s", want, out)
+			}
+		}
+	})
+	t.Run("count", func(t *testing.T) {
+		// Reruns of a test by -count use its golden files again.
+		out, err := runSuite(t, ".", "^TestGoldenByRequest$", "-test.count=2")
+		if err != nil || strings.Contains(out, "collision") {
+			t.Errorf("%v:\n%s", err, out)
+		}
+	})
+}
+
+// TestGoldenChanges shows the summary of the golden files changed by
+// -golden written to the file given by -golden-changes, for PR bots. The
+// suite updates the golden files of a copy of testdata in a subprocess.
+func TestGoldenChanges(t *testing.T) {
+	const run = "^TestGoldenChanges$"
+	if os.Getenv("E2E_SUBPROCESS") == run {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-New", "1")
+			w.Header().Set("X-Version", "2")
+			_, _ = w.Write([]byte(`{"name":"JoJo","age":18,"stands":["Star Platinum","The World"]}`))
+		})
+		mux.HandleFunc("GET /text", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte("Hello, JoJo"))
+		})
+		mux.HandleFunc("POST /added", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+		rn := e2e.New(mux)
+		t.Run("json", func(t *testing.T) {
+			rn.RunTest(t, e2e.NewRequest(http.MethodGet, "/json", nil), http.StatusOK)
+		})
+		t.Run("text", func(t *testing.T) {
+			rn.RunTest(t, e2e.NewRequest(http.MethodGet, "/text", nil), http.StatusOK)
+		})
+		t.Run("added", func(t *testing.T) {
+			rn.RunTest(t, e2e.NewRequest(http.MethodPost, "/added", nil), http.StatusCreated)
+		})
+		return
+	}
+
+	dir := t.TempDir()
+	goldens := map[string]string{
+		"json.golden": "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Type: application/json\r\nX-Old: 1\r\nX-Version: 1\r\n\r\n" +
+			`{"name":"JoJo","nickname":"JoJo","age":17,"stands":["Star Platinum"]}`,
+		"text.golden": "HTTP/1.1 404 Not Found\r\nConnection: close\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n404 page not found\n",
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "testdata", "TestGoldenChanges"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, dump := range goldens {
+		if err := os.WriteFile(filepath.Join(dir, "testdata", "TestGoldenChanges", name), []byte("e2e-golden-format: 3\n"+dump), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, "changes.json")
+	if out, err := runSuite(t, dir, run, "-golden", "-golden-changes="+filename); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Changes []e2e.GoldenChange `json:"changes"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []e2e.GoldenChange{
+		{
+			Golden:    "testdata/TestGoldenChanges/added.golden",
+			Kind:      "added",
+			NewStatus: http.StatusCreated,
+		},
+		{
+			Golden:         "testdata/TestGoldenChanges/json.golden",
+			Kind:           "modified",
+			OldStatus:      http.StatusOK,
+			NewStatus:      http.StatusOK,
+			HeadersAdded:   []string{"X-New"},
+			HeadersRemoved: []string{"X-Old"},
+			HeadersChanged: []string{"X-Version"},
+			FieldsAdded:    []string{"stands#1"},
+			FieldsRemoved:  []string{"nickname"},
+			FieldsChanged:  []string{"age"},
+		},
+		{
+			Golden:      "testdata/TestGoldenChanges/text.golden",
+			Kind:        "modified",
+			OldStatus:   http.StatusNotFound,
+			NewStatus:   http.StatusOK,
+			BodyChanged: true,
+		},
+	}
+	if diff := cmp.Diff(want, got.Changes); diff != "" {
+		t.Errorf("golden changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestVolatileHeaders shows an example of headers changing on every request,
+// which are normalized unless kept to assert them.
+func TestVolatileHeaders(t *testing.T) {
+	rn := e2e.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		requestID := r.Header.Get("X-Request-Id")
+		if requestID == "" {
+			requestID = strconv.FormatInt(now.UnixNano(), 36)
+		}
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+		w.Header().Set("X-Request-Id", requestID)
+		http.SetCookie(w, &http.Cookie{Name: "visit", Value: "1", Expires: now.Add(24 * time.Hour)})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Run("normalized", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/visit", nil)
+		rn.RunTest(t, r, http.StatusNoContent)
+	})
+	t.Run("propagated request id", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/visit", nil, e2e.WithHeader("X-Request-Id", "req-1"), e2e.KeepVolatileHeaders("X-Request-Id"))
+		rn.RunTest(t, r, http.StatusNoContent)
+	})
+}
+
+// TestUserBadges shows an example of arrays in nondeterministic order sorted
+// before the comparison.
+func TestUserBadges(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/user/1/badges", nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.SortArray("items", e2e.ByKey("id")), e2e.SortArray("tags"), e2e.PrettyJSON)
+}
+
+// TestCanonicalJSON shows an example of JSON bodies canonicalized for every
+// request of a Runner, which leaves other bodies as is.
+func TestCanonicalJSON(t *testing.T) {
+	rn := e2e.New(newRouter(), e2e.WithFilters(e2e.CanonicalJSON))
+
+	for _, endpoint := range []string{"/v1/user/1/stats", "/v1/greeting"} {
+		t.Run(APITestName(endpoint, http.StatusOK), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil)
+			rn.RunTest(t, r, http.StatusOK)
+		})
+	}
+}
+
+// TestJCS shows an example of a JSON body in the canonical form of RFC 8785,
+// whose hash is stable across encoders.
+func TestJCS(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/user/1/stats", nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.JCS)
+
+	a, err := e2e.CanonicalizeJCS([]byte(`{"speed": 1.50, "stand": "<Star Platinum>"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := e2e.CanonicalizeJCS([]byte(`{"stand":"\u003cStar Platinum\u003e","speed":15e-1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha256.Sum256(a) != sha256.Sum256(b) {
+		t.Errorf("hashes of %s and %s differ", a, b)
+	}
+}
+
+// TestLegacyUser shows an example of asserting that a deprecated endpoint
+// signals its deprecation and sunset.
+func TestLegacyUser(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/legacy/user", nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.DeprecationHeaders, e2e.PrettyJSON)
+}
+
+// TestTopLevelJSON shows an example of list and scalar endpoints, whose
+// bodies are a top-level array or number instead of an object.
+func TestTopLevelJSON(t *testing.T) {
+	t.Run(APITestName("/v1/users", http.StatusOK), func(t *testing.T) {
+		var users []string
+		r := e2e.NewRequest(http.MethodGet, "/v1/users", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.CaptureResponse(&users), e2e.PaginationLinks(), e2e.PrettyJSON)
+		if len(users) != 2 {
+			t.Errorf("got %d users, want 2", len(users))
+		}
+	})
+	t.Run(APITestName("/v1/user/1/stands/history", http.StatusOK), func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/user/1/stands/history", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.ModifyJSON(map[string]any{"awakened_at": 1677136520}), e2e.PrettyJSON)
+	})
+	t.Run(APITestName("/v1/users/count", http.StatusOK), func(t *testing.T) {
+		var count int
+		r := e2e.NewRequest(http.MethodGet, "/v1/users/count", nil)
+		e2e.RunTest(t, r, http.StatusOK, e2e.CaptureResponse(&count), e2e.PrettyJSON)
+		if count != 5 {
+			t.Errorf("count: %d, want: 5", count)
+		}
+	})
+}
+
+// TestEnvelopedUsers shows an example of a list endpoint whose payload in
+// the registered envelope is an array, rewritten object by object.
+func TestEnvelopedUsers(t *testing.T) {
+	const endpoint = "/v2/users"
+
+	tests := []struct {
+		description []string
+		filter      e2e.ResponseFilter
+	}{
+		{
+			description: []string{"modified"},
+			filter:      e2e.ModifyJSON(map[string]any{"created_time": 1677136520}),
+		},
+		{
+			description: []string{"ignored"},
+			filter:      e2e.IgnoreFields("#0.created_time", "#1.created_time"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil)
+			e2e.RunTest(t, r, http.StatusOK, tt.filter, e2e.PrettyJSON)
+		})
+	}
+}
+
+// TestUserProblem shows an example of error responses in the problem detail
+// format of RFC 9457.
+func TestUserProblem(t *testing.T) {
+	const endpoint = "/v2/users"
+
+	tests := []struct {
+		description []string
+		id          string
+		want        int
+		contentType string
+	}{
+		{
+			description: []string{"found"},
+			id:          "1",
+			want:        http.StatusOK,
+			contentType: "application/json",
+		},
+		{
+			description: []string{"unknown"},
+			id:          "99",
+			want:        http.StatusNotFound,
+			contentType: "application/problem+json",
+		},
+		{
+			description: []string{"malformed"},
+			id:          "jojo",
+			want:        http.StatusBadRequest,
+			contentType: "application/problem+json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, tt.want, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint+"/"+tt.id, nil)
+			e2e.RunTest(t, r, tt.want, e2e.ExpectHeader("Content-Type", tt.contentType), e2e.PrettyJSON)
+		})
+	}
+}
+
+// TestUserEventsUntil shows an example of reading server-sent events until a
+// sentinel event arrives.
+func TestUserEventsUntil(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/users/events", nil)
+	e2e.RunSSE(t, r, http.StatusOK, e2e.UntilEvent(func(e e2e.Event) bool {
+		return strings.Contains(e.Data, "Joseph Joestar")
+	}), e2e.EventTimeout(time.Second))
+}
+
+// TestCrawlAdmin shows an example of a crawl authenticated on every request
+// and limited in the number of pages.
+func TestCrawlAdmin(t *testing.T) {
+	results := e2e.Crawl(t, []string{"/v1/admin", "/v2/user/1"},
+		e2e.CrawlRequestOptions(e2e.WithBearerToken("dio-token")), e2e.MaxPages(2))
+
+	if got, want := len(results), 2; got != want {
+		t.Errorf("pages: %d, want: %d", got, want)
+	}
+	for _, res := range results {
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s: %d, want: %d", res.URL, res.StatusCode, http.StatusOK)
+		}
+	}
+}
+
+// TestMemoize shows an example of clients sharing the responses to reference
+// data instead of requesting it again.
+func TestMemoize(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		newRouter().ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	cache := e2e.NewResponseCache()
+	var sent []*http.Request
+	for _, c := range []*e2e.Client{e2e.NewClient(srv, e2e.Memoize(cache)), e2e.NewClient(srv, e2e.Memoize(cache))} {
+		resp, err := c.Do(e2e.NewRequest(http.MethodGet, "/v2/users/1", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		sent = append(sent, resp.Request)
+	}
+	if sent[1] == nil || sent[1] == sent[0] {
+		t.Error("cached response does not answer the request sent")
+	}
+	if hits != 1 {
+		t.Errorf("requests served: %d, want: 1", hits)
+	}
+
+	rn := e2e.New(nil, e2e.Remote(srv.URL), e2e.WithResponseCache(e2e.NewResponseCache()))
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			rn.RunTest(t, e2e.NewRequest(http.MethodGet, "/v1/user/1", nil), http.StatusOK)
+		})
+	}
+	if hits != 2 {
+		t.Errorf("requests served: %d, want: 2", hits)
+	}
+}
+
+// TestNamespaceFields shows a Runner naming the resources of every request
+// after the run, with golden files independent of the run.
+func TestNamespaceFields(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		_, _ = io.Copy(w, r.Body)
+	})
+	rn := e2e.New(echo, e2e.WithNamespaceFields("name"))
+
+	t.Run("json", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}))
+		rn.RunTest(t, r, http.StatusOK)
+	})
+	t.Run("text", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodPost, "/v1/user", strings.NewReader("JoJo"), e2e.WithContentType("text/plain"))
+		rn.RunTest(t, r, http.StatusOK)
+	})
+	t.Run("no body", func(t *testing.T) {
+		r := e2e.NewRequest(http.MethodGet, "/v1/user", nil)
+		rn.RunTest(t, r, http.StatusOK)
+	})
+}
+
+// TestRunID shows the run ID sent with every request, so that the logs of
+// the system under test can be filtered to a single run.
+func TestRunID(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+	if got := r.Header.Get("X-E2E-Run-ID"); got != e2e.RunID() {
+		t.Errorf("X-E2E-Run-ID: %q, want: %q", got, e2e.RunID())
+	}
+
+	e2e.SetRunIDHeader("X-Request-Run")
+	t.Cleanup(func() { e2e.SetRunIDHeader("X-E2E-Run-ID") })
+	r = e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+	if got := r.Header.Get("X-Request-Run"); got != e2e.RunID() {
+		t.Errorf("X-Request-Run: %q, want: %q", got, e2e.RunID())
+	}
+	if got := r.Header.Get("X-E2E-Run-ID"); got != "" {
+		t.Errorf("X-E2E-Run-ID: %q, want none", got)
+	}
+}
+
+// TestLatencies shows the durations of the requests aggregated by route,
+// which -latency and -latency-json report at the end of the suite.
+func TestLatencies(t *testing.T) {
+	const route = "GET /v1/latency/{id}"
+	latency := func() e2e.RouteLatency {
+		for _, l := range e2e.Latencies() {
+			if l.Route == route {
+				return l
+			}
+		}
+		return e2e.RouteLatency{Route: route}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rn := e2e.New(mux)
+	before := latency()
+	for _, id := range []string{"1", "2"} {
+		t.Run(id, func(t *testing.T) {
+			rn.RunTest(t, e2e.NewRequest(http.MethodGet, "/v1/latency/"+id, nil), http.StatusNoContent)
+		})
+	}
+
+	l := latency()
+	if got := l.Count - before.Count; got != 2 {
+		t.Errorf("requests to %s: %d, want: 2", route, got)
+	}
+	if l.Max > l.Total || l.Mean() > l.Max {
+		t.Errorf("max %s, mean %s and total %s of %s are inconsistent", l.Max, l.Mean(), l.Total, route)
+	}
+}
+
+// TestUniqueNames shows an example of naming the resources created against
+// a shared environment after the run, so that parallel runs do not collide.
+func TestUniqueNames(t *testing.T) {
+	r := e2e.NewRequest(http.MethodPost, "/v1/user", e2e.JSONBody(t, map[string]any{"name": "JoJo"}), e2e.WithNamespace("name"))
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want := e2e.Unique("JoJo"); body.Name != want {
+		t.Errorf("name: %q, want: %q", body.Name, want)
+	}
+	if !strings.HasPrefix(body.Name, e2e.Namespace()) {
+		t.Errorf("name %q is not in namespace %q", body.Name, e2e.Namespace())
+	}
+}
+
+// TestUserLinks shows an example of comparing data other than a response
+// with a golden file.
+func TestUserLinks(t *testing.T) {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, e2e.NewRequest(http.MethodGet, "/v2/user/1", nil))
+	var v any
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	for _, l := range e2e.ExtractLinks(v) {
+		fmt.Fprintf(&b, "%s %s\n", l.Rel, l.Href)
+	}
+	e2e.CompareGolden(t, []byte(b.String()))
+}
+
+// TestUsersActivity shows an example of NDJSON responses kept one record per
+// line in the golden file, with volatile fields masked in every record.
+func TestUsersActivity(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		filters  []e2e.ResponseFilter
+	}{
+		{
+			endpoint: "/v1/users/export",
+			filters:  []e2e.ResponseFilter{e2e.PrettyNDJSON},
+		},
+		{
+			endpoint: "/v1/users/activity",
+			filters:  []e2e.ResponseFilter{e2e.NDJSONRecords(e2e.Mask("$.at", 0), e2e.IgnoreFields("trace"))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(tt.endpoint, http.StatusOK), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, tt.endpoint, nil)
+			e2e.RunTest(t, r, http.StatusOK, tt.filters...)
+		})
+	}
+}
+
+// TestBinaryDigest shows an example of binary bodies recorded as digests in
+// the golden file, with the raw bytes stored next to it.
+func TestBinaryDigest(t *testing.T) {
+	for _, endpoint := range []string{"/v1/user/1/avatar.png", "/static/logo.svg"} {
+		t.Run(APITestName(endpoint, http.StatusOK), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil)
+			e2e.RunTest(t, r, http.StatusOK, e2e.BinaryDigest(e2e.DigestSidecar()))
+		})
+	}
+}
+
+// maskRequestID is a custom ResponseFilter replacing the request ID header,
+// which differs on every response.
+func maskRequestID(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if r.Header.Get("X-Request-Id") != "" {
+		r.Header.Set("X-Request-Id", "***")
+	}
+}
+
+// TestCustomFilter shows an example of unit-testing custom filters against
+// synthetic responses.
+func TestCustomFilter(t *testing.T) {
+	r := filtertest.NewResponse(http.StatusOK,
+		filtertest.WithHeader("X-Request-Id", "8f14e45f"),
+		filtertest.WithJSON(map[string]any{"name": "JoJo", "fetched_at": time.Now().Unix()}),
+	)
+	filtertest.Apply(t, r, maskRequestID, e2e.Mask("$.fetched_at", 0))
+
+	if got := r.Header.Get("X-Request-Id"); got != "***" {
+		t.Errorf("X-Request-Id: %q, want: %q", got, "***")
+	}
+	filtertest.ExpectJSON(t, r, `{"fetched_at": 0, "name": "JoJo"}`)
+
+	r = filtertest.NewResponse(http.StatusNoContent)
+	filtertest.Apply(t, r, maskRequestID)
+	filtertest.ExpectBody(t, r, "")
+}
+
+// TestUsersDownload shows an example of asserting a file download and
+// validating the saved file.
+func TestUsersDownload(t *testing.T) {
+	const endpoint = "/v1/users/export.csv"
+
+	var path string
+	r := e2e.NewRequest(http.MethodGet, endpoint, nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.Download(
+		e2e.AttachmentName("users.csv"),
+		e2e.DownloadType("text/csv"),
+		e2e.SHA256("c773342a842c43f9d68530af30954ee30e1093e3476c26c31469cdb806818371"),
+		e2e.SaveDownload(&path),
+	))
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(records), 4; got != want {
+		t.Errorf("records: %d, want: %d", got, want)
+	}
+}
+
+// TestUserBundle shows an example of a multipart/mixed response written to
+// the golden file part by part, with filters applied to the JSON parts.
+func TestUserBundle(t *testing.T) {
+	r := e2e.NewRequest(http.MethodGet, "/v1/user/1/bundle", nil)
+	e2e.RunTest(t, r, http.StatusOK, e2e.MultipartParts(
+		e2e.PartFilters("application/json", e2e.Mask("$.fetched_at", 0), e2e.PrettyJSON),
+	))
+}
+
+// TestUserPage shows an example of server-rendered pages normalized for the
+// golden file, with the nonces and CSRF tokens replaced.
+func TestUserPage(t *testing.T) {
+	const endpoint = "/v1/user/1/page"
+
+	tests := []struct {
+		description []string
+		opts        []e2e.RequestOption
+	}{
+		{
+			description: []string{"document"},
+		},
+		{
+			description: []string{"fragment"},
+			opts:        []e2e.RequestOption{e2e.WithQuery("partial", "1")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(APITestName(endpoint, http.StatusOK, tt.description...), func(t *testing.T) {
+			r := e2e.NewRequest(http.MethodGet, endpoint, nil, tt.opts...)
+			e2e.RunTest(t, r, http.StatusOK, e2e.NormalizeHTML(e2e.StripNonces(), e2e.StripCSRF()))
+		})
+	}
+}
+
+// TestUserCardNotAcceptable shows an example of asserting consistent
+// negotiation failures for the Accept values generated from the suite
+// config.
+func TestUserCardNotAcceptable(t *testing.T) {
+	e2e.CheckNotAcceptable(t, func(t *testing.T) *http.Request {
+		return e2e.NewRequest(http.MethodGet, "/v1/user/1/card", nil)
+	})
+}
+
+// TestNotAcceptable shows an example of asserting the negotiation failures
+// of every endpoint registered with e2e.RegisterNegotiation.
+func TestNotAcceptable(t *testing.T) {
+	e2e.CheckRegisteredNotAcceptable(t)
+
+	t.Run("unregistered", func(t *testing.T) {
+		// The failures of the check are reported like those of RunTest, so
+		// that XFail and quarantine apply to them.
+		e2e.XFail(t, "no Negotiation is registered for /v1/health")
+		e2e.CheckNotAcceptable(t, func(t *testing.T) *http.Request {
+			return e2e.NewRequest(http.MethodGet, "/v1/health", nil)
+		})
+	})
+}
+
+// runSuite runs the tests of this package matching run with the flags in a
+// subprocess in dir, returning its output, so that tests can check flags
+// acting on testdata and the report of e2e.RunSuite. $E2E_SUBPROCESS is set
+// to run in the subprocess, so that tests can tell they are meant to fail
+// there.
+func runSuite(t *testing.T, dir, run string, flags ...string) (string, error) {
+	t.Helper()
+
+	skipInSubprocess(t)
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, append([]string{"-test.run=" + run}, flags...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "E2E_SUBPROCESS="+run)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// skipInSubprocess skips t in the subprocesses of runSuite, so that they do
+// not start subprocesses in turn.
+func skipInSubprocess(t *testing.T) {
+	t.Helper()
+
+	if _, ok := os.LookupEnv("E2E_SUBPROCESS"); ok {
+		t.Skip("already running in a subprocess")
+	}
+}
+
+// TestReceivedNewGolden shows that -received writes the response of a test
+// without golden files, e.g. a new one, for approval.
+func TestReceivedNewGolden(t *testing.T) {
+	dir := t.TempDir()
+	out, err := runSuite(t, dir, "^TestHealthEndpoint$", "-received")
+	if err == nil {
+		t.Fatalf("tests without golden files passed:\n%s", out)
+	}
+
+	for _, name := range []string{"v1_health_200", "v2_health_200"} {
+		received, err := os.ReadFile(filepath.Join(dir, "testdata", "TestHealthEndpoint", name+".received"))
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		golden, err := os.ReadFile(filepath.Join("testdata", "TestHealthEndpoint", name+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(golden), string(received)); diff != "" {
+			t.Errorf("%s received mismatch (-golden +received):\n%s", name, diff)
+		}
+	}
 }
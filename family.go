@@ -0,0 +1,156 @@
+package e2e
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// Family is the base expectation of an endpoint family, e.g. every endpoint
+// under /v1/, inherited by the tests of its endpoints, so that common checks
+// and normalizers are not repeated in every table entry.
+type Family struct {
+	// Header is the headers every response of the family must have. Tests
+	// override them with OverrideHeader.
+	Header http.Header
+	// Filters are applied to every response of the family before the
+	// filters of the test, which can refine what they normalized.
+	Filters []ResponseFilter
+}
+
+// family is a Family registered for the endpoints matched by pattern.
+type family struct {
+	pattern string
+	mux     *http.ServeMux
+	Family
+}
+
+func newFamily(pattern string, f Family) family {
+	mux := http.NewServeMux()
+	mux.Handle(pattern, http.NotFoundHandler())
+	return family{pattern: pattern, mux: mux, Family: f}
+}
+
+var registeredFamilies []family
+
+// RegisterFamily makes RunTest apply f to the responses of the endpoints
+// matched by pattern in the syntax of http.ServeMux, e.g. "/v1/" or
+// "GET /v1/admin/". The families matching a request apply from the least
+// specific pattern to the most specific by the precedence of ServeMux, so
+// that nested families override the headers and refine the filters of their
+// parents.
+func RegisterFamily(pattern string, f Family) {
+	registeredFamilies = append(registeredFamilies, newFamily(pattern, f))
+}
+
+// WithFamily is like RegisterFamily for the Runner.
+func WithFamily(pattern string, f Family) RunnerOption {
+	return func(rn *Runner) {
+		rn.families = append(rn.families, newFamily(pattern, f))
+	}
+}
+
+// precedence ranks the patterns of the families matching r from the least
+// specific to the most specific by the precedence of http.ServeMux: the
+// pattern a ServeMux of the patterns routes r to is the most specific, and
+// so on with the others. Patterns conflicting with the ones of a ServeMux,
+// being neither more nor less specific, are ranked by a later ServeMux.
+func precedence(r *http.Request, families []family) map[string]int {
+	var patterns []string
+	for _, f := range families {
+		if !slices.Contains(patterns, f.pattern) {
+			patterns = append(patterns, f.pattern)
+		}
+	}
+
+	rank := make(map[string]int, len(patterns))
+	for len(patterns) > 0 {
+		mux := http.NewServeMux()
+		for _, p := range patterns {
+			handle(mux, p)
+		}
+		_, p := mux.Handler(r)
+		if !slices.Contains(patterns, p) {
+			// Unreachable as every pattern matches r.
+			p = patterns[0]
+		}
+		rank[p] = len(patterns)
+		patterns = slices.DeleteFunc(patterns, func(q string) bool { return q == p })
+	}
+	return rank
+}
+
+// handle registers pattern to mux unless it conflicts with the patterns of
+// mux.
+func handle(mux *http.ServeMux, pattern string) {
+	defer func() { _ = recover() }()
+	mux.Handle(pattern, http.NotFoundHandler())
+}
+
+type overrideHeaderKey struct{}
+
+// OverrideHeader overrides the header key expected by the Family of the
+// request with value, or stops checking it when value is empty, for the
+// endpoints deviating from their family.
+func OverrideHeader(key, value string) RequestOption {
+	key = http.CanonicalHeaderKey(key)
+	return func(r *http.Request) {
+		overrides, _ := r.Context().Value(overrideHeaderKey{}).(map[string]string)
+		overrides = maps.Clone(overrides)
+		if overrides == nil {
+			overrides = make(map[string]string)
+		}
+		overrides[key] = value
+		*r = *r.WithContext(context.WithValue(r.Context(), overrideHeaderKey{}, overrides))
+	}
+}
+
+// familyFilters returns the filters inherited by r from the families of rn:
+// the check of the expected headers followed by the filters of the families.
+func (rn *Runner) familyFilters(r *http.Request) []ResponseFilter {
+	var matched []family
+	for _, f := range rn.families {
+		if _, pattern := f.mux.Handler(r); pattern != "" {
+			matched = append(matched, f)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	// The families of the same pattern keep the order of registration.
+	rank := precedence(r, matched)
+	slices.SortStableFunc(matched, func(a, b family) int {
+		return cmp.Compare(rank[a.pattern], rank[b.pattern])
+	})
+
+	header := make(http.Header)
+	var filters []ResponseFilter
+	for _, f := range matched {
+		for k, v := range f.Header {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+		filters = append(filters, f.Filters...)
+	}
+	overrides, _ := r.Context().Value(overrideHeaderKey{}).(map[string]string)
+	for k, v := range overrides {
+		if v == "" {
+			header.Del(k)
+		} else {
+			header.Set(k, v)
+		}
+	}
+
+	check := func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		for _, k := range slices.Sorted(maps.Keys(header)) {
+			if got, want := r.Header.Values(k), header[k]; !slices.Equal(got, want) {
+				errorf(t, r, "HTTP Header %s: %q, want: %q (family)\n", k, got, want)
+			}
+		}
+	}
+	return append([]ResponseFilter{check}, filters...)
+}
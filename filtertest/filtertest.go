@@ -0,0 +1,127 @@
+// Package filtertest tests custom e2e.ResponseFilter functions against
+// synthetic responses built in the test, so that normalization logic is
+// validated without a router, a RunTest flow or golden files.
+package filtertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/satorunooshie/e2e"
+)
+
+// Option configures the response built by NewResponse.
+type Option func(*http.Response)
+
+// NewResponse returns a synthetic response of the status code, as the
+// filters of RunTest receive it, to a GET / request unless WithRequest is
+// given.
+func NewResponse(status int, opts ...Option) *http.Response {
+	r := &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    httptest.NewRequest(http.MethodGet, "/", nil),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithHeader adds the header to the response.
+func WithHeader(key, value string) Option {
+	return func(r *http.Response) {
+		r.Header.Add(key, value)
+	}
+}
+
+// WithBody sets the body of the response.
+func WithBody(body string) Option {
+	return func(r *http.Response) {
+		r.Body = io.NopCloser(bytes.NewBufferString(body))
+		r.ContentLength = int64(len(body))
+	}
+}
+
+// WithJSON sets the body of the response to v encoded as JSON, and
+// Content-Type to application/json. It panics when v cannot be encoded.
+func WithJSON(v any) Option {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("filtertest: WithJSON: %v", err))
+	}
+	return func(r *http.Response) {
+		r.Header.Set("Content-Type", "application/json")
+		WithBody(string(data))(r)
+	}
+}
+
+// WithRequest sets the request the response answers, e.g. one built by
+// e2e.NewRequest with the options the filter depends on.
+func WithRequest(req *http.Request) Option {
+	return func(r *http.Response) {
+		r.Request = req
+	}
+}
+
+// Apply applies the filters to r in order, as RunTest does, and returns the
+// resulting body. The body of r is kept, so that r can be inspected or
+// filtered further.
+func Apply(t *testing.T, r *http.Response, filters ...e2e.ResponseFilter) []byte {
+	t.Helper()
+
+	for _, f := range filters {
+		f(t, r)
+	}
+	return Body(t, r)
+}
+
+// Body returns the body of r, which is kept for later reads.
+func Body(t *testing.T, r *http.Response) []byte {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body
+}
+
+// ExpectBody fails t when the body of r is not want.
+func ExpectBody(t *testing.T, r *http.Response, want string) {
+	t.Helper()
+
+	if got := string(Body(t, r)); got != want {
+		t.Errorf("body mismatch (-want +got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+// ExpectJSON fails t when the JSON body of r is not equal to the JSON text
+// want, regardless of formatting and the order of the object keys.
+func ExpectJSON(t *testing.T, r *http.Response, want string) {
+	t.Helper()
+
+	var got, wantV any
+	if err := json.Unmarshal(Body(t, r), &got); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantV); err != nil {
+		t.Fatalf("want is not JSON: %v", err)
+	}
+	if diff := cmp.Diff(wantV, got); diff != "" {
+		t.Errorf("JSON body mismatch (-want +got):\n%s", diff)
+	}
+}
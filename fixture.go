@@ -0,0 +1,70 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+)
+
+// BodyOption configures BodyFromFile.
+type BodyOption func(*bodyConfig)
+
+type bodyConfig struct {
+	data     any
+	template bool
+}
+
+// TemplateData makes BodyFromFile expand the file as a text/template with
+// data, e.g. for fixtures containing {{.UserID}}.
+func TemplateData(data any) BodyOption {
+	return func(c *bodyConfig) {
+		c.data = data
+		c.template = true
+	}
+}
+
+// BodyFromFile returns the content of the fixture file as a Body, so that
+// large payloads live in testdata next to the golden files instead of in Go
+// code. Its Content-Type is guessed from the extension of the file.
+func BodyFromFile(t *testing.T, filename string, opts ...BodyOption) io.Reader {
+	t.Helper()
+
+	var cfg bodyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := bytes.NewBuffer(data)
+	if cfg.template {
+		tmpl, err := template.New(filepath.Base(filename)).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = new(bytes.Buffer)
+		if err := tmpl.Execute(body, cfg.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return typedBody{Buffer: body, contentType: contentType}
+}
+
+// TemplateBody is like BodyFromFile expanding the file as a text/template
+// with data, e.g. for fixtures containing {{.UserID}}.
+func TemplateBody(t *testing.T, filename string, data map[string]any) io.Reader {
+	t.Helper()
+
+	return BodyFromFile(t, filename, TemplateData(data))
+}
@@ -1,5 +1,15 @@
 module github.com/satorunooshie/e2e
 
-go 1.22
+go 1.24
 
-require github.com/google/go-cmp v0.6.0
+require (
+	github.com/google/go-cmp v0.6.0
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a
+	google.golang.org/grpc v1.72.2
+	google.golang.org/protobuf v1.36.5
+)
+
+require golang.org/x/sys v0.30.0 // indirect
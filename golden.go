@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+var migrateGoldens = flag.Bool("migrate-golden", false, "rewrite golden files of older formats in the current format")
+
+// goldenFormat is the version of the format of the golden files written by
+// this package. It is recorded in the first line of every golden file, and
+// must be incremented with a migration whenever the format changes. Files
+// without the line are of version 1.
+const goldenFormat = 3
+
+const goldenFormatPrefix = "e2e-golden-format: "
+
+// goldenMigrations upgrades the content of golden files of the version to
+// the next version.
+var goldenMigrations = map[int]func(data []byte) ([]byte, error){
+	// Version 2 only added the format line.
+	1: func(data []byte) ([]byte, error) { return data, nil },
+	// Version 3 sorts the values of repeated headers.
+	2: func(data []byte) ([]byte, error) { return sortDumpHeaderValues(data), nil },
+}
+
+// encodeGolden returns data prefixed with the format line.
+func encodeGolden(data []byte) []byte {
+	header := goldenFormatPrefix + strconv.Itoa(goldenFormat) + "\n"
+	return append([]byte(header), data...)
+}
+
+// decodeGolden returns the content of the golden file data in the current
+// format, and the version it was written in. Files of older versions are
+// migrated, while files of newer versions are an error.
+func decodeGolden(data []byte) ([]byte, int, error) {
+	version := 1
+	if rest, ok := bytes.CutPrefix(data, []byte(goldenFormatPrefix)); ok {
+		line, body, _ := bytes.Cut(rest, []byte("\n"))
+		v, err := strconv.Atoi(string(line))
+		if err != nil {
+			return nil, 0, fmt.Errorf("malformed golden format %q", line)
+		}
+		version, data = v, body
+	}
+	if version > goldenFormat {
+		return nil, version, fmt.Errorf("golden format %d is newer than the format %d of this version of e2e; upgrade github.com/satorunooshie/e2e", version, goldenFormat)
+	}
+	body := data
+	for v := version; v < goldenFormat; v++ {
+		migrate, ok := goldenMigrations[v]
+		if !ok {
+			return nil, version, fmt.Errorf("golden format %d is no longer supported; regenerate it with -golden", version)
+		}
+		var err error
+		if body, err = migrate(body); err != nil {
+			return nil, version, fmt.Errorf("migrating golden format %d: %w", v, err)
+		}
+	}
+	return body, version, nil
+}
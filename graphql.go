@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// GraphQLBody encodes a GraphQL request of query and variables, which may be
+// nil, and returns it as an io.Reader.
+func GraphQLBody(t *testing.T, query string, variables map[string]any) io.Reader {
+	t.Helper()
+
+	m := map[string]any{"query": query}
+	if variables != nil {
+		m["variables"] = variables
+	}
+	return JSONBody(t, m)
+}
+
+// graphQLResponse decodes the GraphQL response of r, leaving r readable.
+func graphQLResponse(t *testing.T, r *http.Response) map[string]any {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var v map[string]any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("GraphQL response: %v", err)
+	}
+	return v
+}
+
+// NoGraphQLErrors fails the test when the GraphQL response has errors, since
+// GraphQL servers usually respond 200 even on errors.
+func NoGraphQLErrors(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if bodyless(r.StatusCode) {
+		return
+	}
+	v := graphQLResponse(t, r)
+	if errs, _ := v["errors"].([]any); len(errs) > 0 {
+		errorf(t, r, "GraphQL errors: %v\n", errs)
+	}
+}
+
+// MaskGraphQLErrors replaces the fields of every error of the GraphQL
+// response with "***", e.g. locations depending on the formatting of
+// the query or paths of resolvers being refactored. With no fields,
+// "locations" and "path" are masked.
+func MaskGraphQLErrors(fields ...string) ResponseFilter {
+	if len(fields) == 0 {
+		fields = []string{"locations", "path"}
+	}
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		v := graphQLResponse(t, r)
+		errs, _ := v["errors"].([]any)
+		for _, e := range errs {
+			m, ok := e.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, f := range fields {
+				if _, ok := m[f]; ok {
+					m[f] = "***"
+				}
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(&v); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(buf)
+	}
+}
@@ -0,0 +1,218 @@
+package e2e
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLOption configures NormalizeHTML.
+type HTMLOption func(*htmlConfig)
+
+type htmlConfig struct {
+	nonces bool
+	csrf   []string
+}
+
+// StripNonces makes NormalizeHTML replace the nonce attributes of scripts and
+// styles, and the nonces in the Content-Security-Policy header, with "***",
+// since they differ on every response.
+func StripNonces() HTMLOption {
+	return func(c *htmlConfig) {
+		c.nonces = true
+	}
+}
+
+// defaultCSRFNames are the names of CSRF tokens of common web frameworks.
+var defaultCSRFNames = []string{"csrf_token", "csrf-token", "_csrf", "_token", "csrfmiddlewaretoken", "authenticity_token", "__RequestVerificationToken"}
+
+// StripCSRF makes NormalizeHTML replace the values of the form fields and
+// meta tags of the names, e.g. <input type="hidden" name="_csrf"> and
+// <meta name="csrf-token">, with "***". Without names, the CSRF tokens of
+// common web frameworks are replaced.
+func StripCSRF(names ...string) HTMLOption {
+	if len(names) == 0 {
+		names = defaultCSRFNames
+	}
+	return func(c *htmlConfig) {
+		c.csrf = append(c.csrf, names...)
+	}
+}
+
+// PrettyHTML is a ResponseFilter formatting HTML responses like NormalizeHTML
+// without options.
+func PrettyHTML(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	NormalizeHTML()(t, r)
+}
+
+// NormalizeHTML returns a ResponseFilter parsing HTML responses and writing
+// them back with an element per line indented by depth, attributes sorted by
+// name and the whitespace of text collapsed, so that golden files of
+// server-rendered pages do not change with the formatting of templates.
+// Text in pre, textarea, script and style is kept as is. Bodies without
+// <html> or a doctype are formatted as fragments, e.g. partial pages.
+func NormalizeHTML(opts ...HTMLOption) ResponseFilter {
+	var cfg htmlConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		if !strings.Contains(r.Header.Get("Content-Type"), "html") {
+			t.Fatal("Response is not HTML")
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var nodes []*html.Node
+		if isHTMLDocument(body) {
+			doc, err := html.Parse(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes = []*html.Node{doc}
+		} else {
+			context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+			if nodes, err = html.ParseFragment(bytes.NewReader(body), context); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var buf bytes.Buffer
+		for _, n := range nodes {
+			cfg.strip(n)
+			writeHTML(&buf, n, 0)
+		}
+		if cfg.nonces {
+			if csp := r.Header.Get("Content-Security-Policy"); csp != "" {
+				r.Header.Set("Content-Security-Policy", cspNonce.ReplaceAllString(csp, "'nonce-***'"))
+			}
+		}
+		r.Body = io.NopCloser(&buf)
+	}
+}
+
+var (
+	htmlDocument = regexp.MustCompile(`(?i)<!doctype|<html[\s>]`)
+	cspNonce     = regexp.MustCompile(`'nonce-[^']*'`)
+)
+
+func isHTMLDocument(body []byte) bool {
+	return htmlDocument.Match(body)
+}
+
+// strip replaces the nonces and the CSRF tokens in n and its descendants.
+func (c *htmlConfig) strip(n *html.Node) {
+	if n.Type == html.ElementNode {
+		name := htmlAttr(n, "name")
+		for i, a := range n.Attr {
+			switch {
+			case c.nonces && a.Key == "nonce":
+				n.Attr[i].Val = "***"
+			case name != "" && slices.Contains(c.csrf, name) &&
+				(n.DataAtom == atom.Input && a.Key == "value" || n.DataAtom == atom.Meta && a.Key == "content"):
+				n.Attr[i].Val = "***"
+			}
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.strip(child)
+	}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlVoidElements have no content nor end tag.
+var htmlVoidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true, atom.Hr: true, atom.Img: true,
+	atom.Input: true, atom.Link: true, atom.Meta: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+}
+
+// htmlPreformatted elements keep their content as is.
+var htmlPreformatted = map[atom.Atom]bool{
+	atom.Pre: true, atom.Textarea: true, atom.Script: true, atom.Style: true,
+}
+
+// writeHTML writes n indented by depth.
+func writeHTML(buf *bytes.Buffer, n *html.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n.Type {
+	case html.DocumentNode:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeHTML(buf, child, depth)
+		}
+	case html.DoctypeNode:
+		buf.WriteString(indent + "<!DOCTYPE " + n.Data + ">\n")
+	case html.CommentNode:
+		buf.WriteString(indent + "<!--" + n.Data + "-->\n")
+	case html.TextNode:
+		if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+			buf.WriteString(indent + html.EscapeString(text) + "\n")
+		}
+	case html.ElementNode:
+		buf.WriteString(indent + "<" + n.Data)
+		attrs := slices.Clone(n.Attr)
+		slices.SortStableFunc(attrs, func(a, b html.Attribute) int {
+			return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Key, b.Key))
+		})
+		for _, a := range attrs {
+			key := a.Key
+			if a.Namespace != "" {
+				key = a.Namespace + ":" + key
+			}
+			buf.WriteString(" " + key + `="` + html.EscapeString(a.Val) + `"`)
+		}
+		buf.WriteString(">")
+		if htmlVoidElements[n.DataAtom] {
+			buf.WriteString("\n")
+			return
+		}
+
+		switch {
+		case htmlPreformatted[n.DataAtom]:
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.TextNode && n.DataAtom != atom.Pre && n.DataAtom != atom.Textarea {
+					// Script and style are raw text, which is not escaped.
+					buf.WriteString(child.Data)
+				} else {
+					_ = html.Render(buf, child)
+				}
+			}
+		case n.FirstChild == nil:
+		case n.FirstChild == n.LastChild && n.FirstChild.Type == html.TextNode:
+			// Elements of a single text stay on one line, e.g. <title>.
+			buf.WriteString(html.EscapeString(strings.Join(strings.Fields(n.FirstChild.Data), " ")))
+		default:
+			buf.WriteString("\n")
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				writeHTML(buf, child, depth+1)
+			}
+			buf.WriteString(indent)
+		}
+		buf.WriteString("</" + n.Data + ">\n")
+	}
+}
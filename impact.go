@@ -0,0 +1,104 @@
+package e2e
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// handlerSources holds the source files of the handlers of the routes served
+// in-process, so that the results written by -results-json map tests to the
+// code they exercise for test impact analysis.
+var handlerSources sync.Map // map[string]string
+
+// sourceRecorder is a ResponseRecorder recording the call stack of the first
+// write of the response, which leads to the handler of the route however
+// many middleware wrap the ServeMux routing to it.
+type sourceRecorder struct {
+	*httptest.ResponseRecorder
+	pcs []uintptr
+}
+
+func newSourceRecorder() *sourceRecorder {
+	return &sourceRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (w *sourceRecorder) record() {
+	if w.pcs == nil {
+		pcs := make([]uintptr, 64)
+		w.pcs = pcs[:runtime.Callers(3, pcs)]
+	}
+}
+
+func (w *sourceRecorder) WriteHeader(code int) {
+	w.record()
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+func (w *sourceRecorder) Write(b []byte) (int, error) {
+	w.record()
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *sourceRecorder) WriteString(s string) (int, error) {
+	w.record()
+	return w.ResponseRecorder.WriteString(s)
+}
+
+// recordHandlerSource records the source file of the handler of router
+// which served r. The handler is the function called by the innermost
+// ServeMux on the stack of the first write to w, so that middleware wrapping
+// the ServeMux, in the router or around it, are seen through. Otherwise it
+// is the handler of router when router is a ServeMux or a function.
+func recordHandlerSource(router http.Handler, r *http.Request, w *sourceRecorder) {
+	if file := muxHandlerFile(w.pcs); file != "" {
+		handlerSources.Store(routeOf(r), file)
+		return
+	}
+
+	h := router
+	if mux, ok := router.(*http.ServeMux); ok {
+		h, _ = mux.Handler(r)
+	}
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return
+	}
+	file, _ := f.FileLine(f.Entry())
+	handlerSources.Store(routeOf(r), file)
+}
+
+// muxHandlerFile returns the source file of the function called by the
+// innermost ServeMux in the call stack pcs, skipping the adapters of
+// net/http, or "" when no ServeMux is on the stack.
+func muxHandlerFile(pcs []uintptr) string {
+	var file string
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		switch {
+		case f.Function == "net/http.(*ServeMux).ServeHTTP":
+			return file
+		case !strings.HasPrefix(f.Function, "net/http."):
+			file = f.File
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// handlerSource returns the source file of the handler of route, if known.
+func handlerSource(route string) string {
+	if file, ok := handlerSources.Load(route); ok {
+		return file.(string)
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// CanonicalizeJCS returns the JSON text data in the JSON Canonicalization
+// Scheme of RFC 8785: no insignificant whitespace, object members sorted by
+// the UTF-16 code units of their names, numbers serialized as ECMAScript
+// does, and strings with the minimal escaping. The output is stable across Go
+// versions and encoders, so that its hash identifies the content, e.g. to
+// deduplicate responses.
+func CanonicalizeJCS(data []byte) ([]byte, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after the JSON value")
+	}
+	var buf bytes.Buffer
+	if err := writeJCS(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JCS is a ResponseFilter rewriting JSON bodies with CanonicalizeJCS, for
+// golden files which must hold the exact bytes clients hash or sign.
+// Responses which are not JSON are left as is, like with CanonicalJSON.
+func JCS(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if bodyless(r.StatusCode) || !isJSON(r.Header.Get("Content-Type")) {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if body, err = CanonicalizeJCS(body); err != nil {
+			t.Fatalf("JCS: %v\n", err)
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+func writeJCS(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := slices.SortedFunc(maps.Keys(v), compareUTF16)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJCSString(buf, k)
+			buf.WriteByte(':')
+			if err := writeJCS(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJCS(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil || math.IsInf(f, 0) {
+			return fmt.Errorf("number %s is not an IEEE 754 double", v)
+		}
+		buf.WriteString(es6Number(f))
+	case string:
+		writeJCSString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// compareUTF16 orders strings by their UTF-16 code units, as RFC 8785
+// sorts object members.
+func compareUTF16(a, b string) int {
+	return slices.Compare(utf16.Encode([]rune(a)), utf16.Encode([]rune(b)))
+}
+
+// writeJCSString writes s escaping only what JSON requires, with control
+// characters in lowercase hexadecimal.
+func writeJCSString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// es6Number formats f as Number.prototype.toString of ECMAScript does.
+func es6Number(f float64) string {
+	if f == 0 {
+		// Including -0.
+		return "0"
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// The shortest digits d.ddd and the exponent of f.
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, _ := strconv.Atoi(exp)
+	k, n := len(digits), e+1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+	s := sign + digits[:1]
+	if k > 1 {
+		s += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return s + "e+" + strconv.Itoa(n-1)
+	}
+	return s + "e" + strconv.Itoa(n-1)
+}
@@ -0,0 +1,284 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// jsonPathSegment is a step of a JSONPath selecting children of a value.
+type jsonPathSegment struct {
+	// recursive selects the children of the value and all its descendants,
+	// as in "..name".
+	recursive bool
+	wildcard  bool
+	name      string
+	isName    bool
+	indexes   []int
+	slice     *jsonPathSlice
+}
+
+// jsonPathSlice is an array slice "[start:end:step]".
+type jsonPathSlice struct {
+	start, end, step *int
+}
+
+// jsonPathMatch is a value selected by a JSONPath, with the object or array
+// holding it so that it can be replaced.
+type jsonPathMatch struct {
+	parent any
+	key    any // string or int
+	value  any
+}
+
+// parseJSONPath parses the subset of JSONPath (RFC 9535) of the child
+// segments ".name", "['name']", "[0,1]", "[start:end:step]" and "[*]" or
+// ".*", each of which may follow ".." for recursive descent.
+func parseJSONPath(s string) ([]jsonPathSegment, error) {
+	rest, ok := strings.CutPrefix(s, "$")
+	if !ok {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	var segs []jsonPathSegment
+	for rest != "" {
+		var seg jsonPathSegment
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.recursive = true
+			rest = rest[2:]
+			if !strings.HasPrefix(rest, "[") {
+				rest = "." + rest
+			}
+		}
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("empty name in %q", s)
+			case "*":
+				seg.wildcard = true
+			default:
+				seg.name, seg.isName = name, true
+			}
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in %q", s)
+			}
+			if err := parseJSONPathSelector(&seg, rest[1:end]); err != nil {
+				return nil, fmt.Errorf("%w in %q", err, s)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", rest, s)
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+// parseJSONPathSelector parses the selector within brackets into seg.
+func parseJSONPathSelector(seg *jsonPathSegment, sel string) error {
+	sel = strings.TrimSpace(sel)
+	switch {
+	case sel == "*":
+		seg.wildcard = true
+	case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+		seg.name, seg.isName = sel[1:len(sel)-1], true
+	case strings.Contains(sel, ":"):
+		parts := strings.Split(sel, ":")
+		if len(parts) > 3 {
+			return fmt.Errorf("malformed slice [%s]", sel)
+		}
+		seg.slice = new(jsonPathSlice)
+		bounds := []**int{&seg.slice.start, &seg.slice.end, &seg.slice.step}
+		for i, p := range parts {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return fmt.Errorf("malformed slice [%s]", sel)
+			}
+			*bounds[i] = &n
+		}
+		if step := seg.slice.step; step != nil && *step <= 0 {
+			return fmt.Errorf("slice step must be positive in [%s]", sel)
+		}
+	default:
+		for _, p := range strings.Split(sel, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return fmt.Errorf("malformed selector [%s]", sel)
+			}
+			seg.indexes = append(seg.indexes, n)
+		}
+	}
+	return nil
+}
+
+// selectJSONPath returns the values of v selected by segs.
+func selectJSONPath(v any, segs []jsonPathSegment) []jsonPathMatch {
+	nodes := []jsonPathMatch{{value: v}}
+	for _, seg := range segs {
+		var next []jsonPathMatch
+		for _, n := range nodes {
+			candidates := []any{n.value}
+			if seg.recursive {
+				candidates = append(candidates, descendants(n.value)...)
+			}
+			for _, c := range candidates {
+				next = append(next, selectChildren(c, seg)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// selectChildren returns the children of v selected by seg.
+func selectChildren(v any, seg jsonPathSegment) []jsonPathMatch {
+	var matches []jsonPathMatch
+	switch v := v.(type) {
+	case map[string]any:
+		switch {
+		case seg.wildcard:
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				matches = append(matches, jsonPathMatch{parent: v, key: k, value: v[k]})
+			}
+		case seg.isName:
+			if c, ok := v[seg.name]; ok {
+				matches = append(matches, jsonPathMatch{parent: v, key: seg.name, value: c})
+			}
+		}
+	case []any:
+		var indexes []int
+		switch {
+		case seg.wildcard:
+			for i := range v {
+				indexes = append(indexes, i)
+			}
+		case seg.slice != nil:
+			indexes = seg.slice.indexes(len(v))
+		default:
+			for _, i := range seg.indexes {
+				if i < 0 {
+					i += len(v)
+				}
+				indexes = append(indexes, i)
+			}
+		}
+		for _, i := range indexes {
+			if i >= 0 && i < len(v) {
+				matches = append(matches, jsonPathMatch{parent: v, key: i, value: v[i]})
+			}
+		}
+	}
+	return matches
+}
+
+// indexes returns the indexes of an array of length n in the slice.
+func (s *jsonPathSlice) indexes(n int) []int {
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, 0), n)
+	}
+	step := 1
+	if s.step != nil {
+		step = *s.step
+	}
+	var indexes []int
+	for i := bound(s.start, 0); i < bound(s.end, n); i += step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// descendants returns the values within v at any depth in document order.
+func descendants(v any) []any {
+	var values []any
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			values = append(values, v[k])
+			values = append(values, descendants(v[k])...)
+		}
+	case []any:
+		for _, c := range v {
+			values = append(values, c)
+			values = append(values, descendants(c)...)
+		}
+	}
+	return values
+}
+
+// Mask returns a ResponseFilter replacing every value of the JSON response
+// body selected by the JSONPath path with value, e.g.
+// Mask("$.items[*].user.id", 0) or Mask("$..created_time", 0), so that
+// volatile fields at any depth are addressed with one string instead of
+// nested maps of ModifyJSON. Wildcards, array indexes and slices, and
+// recursive descent are supported. $ is the payload of the registered
+// Envelope. With -strict, paths that select nothing fail the test.
+func Mask(path string, value any) ResponseFilter {
+	segs, err := parseJSONPath(path)
+	if err != nil {
+		panic(fmt.Sprintf("e2e: Mask(%q): %v", path, err))
+	}
+	if len(segs) == 0 {
+		panic(fmt.Sprintf("e2e: Mask(%q): cannot mask the root", path))
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		var tmp any
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+
+		root := payload(runnerOf(r.Request).envelope, tmp)
+		matches := selectJSONPath(root, segs)
+		if len(matches) == 0 && *strictMode {
+			errorf(t, r, "Mask: path %q matched nothing\n", path)
+		}
+		for _, m := range matches {
+			switch parent := m.parent.(type) {
+			case map[string]any:
+				parent[m.key.(string)] = value
+			case []any:
+				parent[m.key.(int)] = value
+			}
+		}
+
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(body)
+	}
+}
@@ -0,0 +1,167 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// LinkOption configures ValidateLinks.
+type LinkOption func(*linkConfig)
+
+type linkConfig struct {
+	golden bool
+}
+
+// GoldenLinks makes ValidateLinks compare the link graph of the response
+// with the golden file <name>.links.golden.
+func GoldenLinks() LinkOption {
+	return func(c *linkConfig) {
+		c.golden = true
+	}
+}
+
+// Link is a hypermedia link found in a response.
+type Link struct {
+	// Rel is the dot-separated path of the link relation, e.g.
+	// "_links.self" or "data.links.next".
+	Rel  string
+	Href string
+}
+
+// ValidateLinks is a ResponseFilter validating the hypermedia links of JSON
+// responses in "_links" (HAL) and "links" (JSON:API) objects at any depth.
+// Every link within the service must respond to GET with a status below
+// 400 when sent as RunTest sends requests: to the router with the
+// middleware, over a socket, or to the live server. Templated links are not
+// followed.
+func ValidateLinks(opts ...LinkOption) ResponseFilter {
+	var cfg linkConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Fatal(err)
+		}
+		links := ExtractLinks(v)
+
+		checked := make(map[string]bool)
+		var send func(*http.Request) (*http.Response, error)
+		for _, l := range links {
+			path, ok := servicePath(r, l.Href)
+			if !ok || checked[path] {
+				continue
+			}
+			checked[path] = true
+
+			if send == nil {
+				send = runnerOf(r.Request).sender(t, r.Request)
+			}
+			got, err := send(NewRequest(http.MethodGet, path, nil))
+			if err != nil {
+				errorf(t, r, "link %s (%s): %v\n", l.Rel, l.Href, err)
+				continue
+			}
+			got.Body.Close()
+			if got.StatusCode >= http.StatusBadRequest {
+				errorf(t, r, "link %s (%s): HTTP StatusCode: %d\n", l.Rel, l.Href, got.StatusCode)
+			}
+		}
+
+		if cfg.golden {
+			var buf bytes.Buffer
+			for _, l := range links {
+				fmt.Fprintf(&buf, "%s -> %s\n", l.Rel, l.Href)
+			}
+			compareSidecar(t, ".links.golden", buf.Bytes())
+		}
+	}
+}
+
+// ExtractLinks returns the links in "_links" and "links" objects of v, which
+// is decoded from JSON, sorted by relation.
+func ExtractLinks(v any) []Link {
+	var links []Link
+	extractLinks(v, "", &links)
+	slices.SortFunc(links, func(a, b Link) int {
+		return strings.Compare(a.Rel+" "+a.Href, b.Rel+" "+b.Href)
+	})
+	return links
+}
+
+func extractLinks(v any, path string, links *[]Link) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, sub := range v {
+			if rels, ok := sub.(map[string]any); ok && (k == "_links" || k == "links") {
+				for rel, l := range rels {
+					appendLinks(l, joinPath(joinPath(path, k), rel), links)
+				}
+				continue
+			}
+			extractLinks(sub, joinPath(path, k), links)
+		}
+	case []any:
+		for _, sub := range v {
+			extractLinks(sub, path, links)
+		}
+	}
+}
+
+// appendLinks appends the links of a relation, which is either an href, an
+// object with href or an array of them.
+func appendLinks(v any, rel string, links *[]Link) {
+	switch v := v.(type) {
+	case string:
+		*links = append(*links, Link{Rel: rel, Href: v})
+	case map[string]any:
+		if templated, _ := v["templated"].(bool); templated {
+			return
+		}
+		if href, ok := v["href"].(string); ok {
+			*links = append(*links, Link{Rel: rel, Href: href})
+		}
+	case []any:
+		for _, l := range v {
+			appendLinks(l, rel, links)
+		}
+	}
+}
+
+// servicePath returns the path and query of href when it refers to the
+// service serving r.
+func servicePath(r *http.Response, href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	if u.IsAbs() || u.Host != "" {
+		if r.Request == nil || u.Host != r.Request.Host {
+			return "", false
+		}
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	return u.RequestURI(), true
+}
@@ -0,0 +1,79 @@
+package e2e
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// MalformedJSON is a request body exercising an edge case of JSON parsers.
+type MalformedJSON struct {
+	Name string
+	Body string
+	// MayAccept reports whether handlers may accept the body because parsers
+	// disagree on it. Otherwise it must be rejected with 4xx.
+	MayAccept bool
+}
+
+// MalformedJSONBodies is the request bodies sent by CheckMalformedJSON.
+var MalformedJSONBodies = []MalformedJSON{
+	{Name: "empty", Body: ``},
+	{Name: "whitespace only", Body: " \n\t"},
+	{Name: "truncated", Body: `{"name":`},
+	{Name: "duplicate keys", Body: `{"name":"a","name":"b"}`, MayAccept: true},
+	{Name: "trailing comma in object", Body: `{"name":"a",}`},
+	{Name: "trailing comma in array", Body: `{"names":["a",]}`},
+	{Name: "byte order mark", Body: "\ufeff{}", MayAccept: true},
+	{Name: "single quotes", Body: `{'name':'a'}`},
+	{Name: "unquoted key", Body: `{name:"a"}`},
+	{Name: "comment", Body: `{"name":"a"/* comment */}`},
+	{Name: "NaN", Body: `{"n":NaN}`},
+	{Name: "leading zero", Body: `{"n":01}`},
+	{Name: "huge exponent", Body: `{"n":1e999999}`, MayAccept: true},
+	{Name: "unescaped control character", Body: "{\"name\":\"a\x01\"}"},
+	{Name: "invalid escape", Body: `{"name":"\x"}`},
+	{Name: "lone surrogate", Body: `{"name":"\ud800"}`, MayAccept: true},
+	{Name: "trailing garbage", Body: `{} {}`, MayAccept: true},
+	{Name: "deeply nested arrays", Body: `{"a":` + strings.Repeat("[", 100000) + strings.Repeat("]", 100000) + `}`},
+	{Name: "deeply nested objects", Body: strings.Repeat(`{"a":`, 100000) + `1` + strings.Repeat("}", 100000)},
+}
+
+// CheckMalformedJSON sends each of MalformedJSONBodies to the router as a
+// JSON request body, asserting controlled rejection: the handler must
+// respond 4xx, or anything but 5xx for bodies parsers disagree on. It
+// protects against parser-differential bugs.
+func CheckMalformedJSON(t *testing.T, method, endpoint string, options ...RequestOption) {
+	t.Helper()
+
+	defaultRunner().CheckMalformedJSON(t, method, endpoint, options...)
+}
+
+// CheckMalformedJSON is like CheckMalformedJSON but sends the requests with
+// rn.
+func (rn *Runner) CheckMalformedJSON(t *testing.T, method, endpoint string, options ...RequestOption) {
+	t.Helper()
+
+	var send func(*http.Request) (*http.Response, error)
+	for _, m := range MalformedJSONBodies {
+		r := NewRequest(method, endpoint, bytes.NewReader([]byte(m.Body)), options...)
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		if send == nil {
+			send = rn.sender(t, r)
+		}
+		got, err := send(r)
+		if err != nil {
+			t.Errorf("%s: %v\n", m.Name, err)
+			continue
+		}
+
+		switch {
+		case got.StatusCode >= http.StatusInternalServerError:
+			t.Errorf("%s: HTTP StatusCode: %d\n", m.Name, got.StatusCode)
+		case !m.MayAccept && got.StatusCode < http.StatusBadRequest:
+			t.Errorf("%s: HTTP StatusCode: %d, want: 4xx\n", m.Name, got.StatusCode)
+		}
+	}
+}
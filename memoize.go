@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ResponseCache memoizes the responses of read-only requests, so that
+// matrix-style tests fetching the same reference data repeatedly do not
+// multiply the load on a shared environment.
+type ResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+// NewResponseCache returns an empty ResponseCache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{entries: make(map[string]cachedResponse)}
+}
+
+// Memoize makes the client serve GET and HEAD requests from cache when a
+// request with the same method, URL, headers and body was sent before.
+// The cache can be shared by clients.
+func Memoize(cache *ResponseCache) ClientOption {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// responseCache is the ResponseCache of the requests sent to the live server
+// by the package-level functions.
+var responseCache *ResponseCache
+
+// RegisterResponseCache makes RunTest serve GET and HEAD requests to the live
+// server set by -base-url or $E2E_BASE_URL from cache, as Memoize does, so
+// that suites run against a shared environment send each reference request
+// once. Requests served in process are not cached.
+func RegisterResponseCache(cache *ResponseCache) {
+	responseCache = cache
+}
+
+// WithResponseCache is like RegisterResponseCache for the Runner, caching the
+// responses of the live server set by Remote.
+func WithResponseCache(cache *ResponseCache) RunnerOption {
+	return func(rn *Runner) {
+		rn.cache = cache
+	}
+}
+
+// cacheKey returns the key of r in a ResponseCache. ok is false when r is not
+// read-only. The body of r is restored after reading it.
+func cacheKey(r *http.Request) (key string, ok bool, err error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false, nil
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Method + " " + r.URL.String() + "\n")
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		b.WriteString(k + ": " + strings.Join(r.Header[k], ", ") + "\n")
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", false, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		b.WriteString("\n")
+		b.Write(body)
+	}
+	return b.String(), true, nil
+}
+
+// load returns the response stored for key, answering r.
+func (c *ResponseCache) load(key string, r *http.Request) (*http.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	resp := *e.resp
+	resp.Header = e.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(e.body))
+	resp.Request = r
+	return &resp, true
+}
+
+func (c *ResponseCache) store(key string, resp *http.Response, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := *resp
+	e.Header = resp.Header.Clone()
+	e.Body = nil
+	c.entries[key] = cachedResponse{resp: &e, body: body}
+}
@@ -0,0 +1,51 @@
+package e2e
+
+import (
+	"flag"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var bypass = flag.String("bypass", "", `comma-separated names of the middleware reported by Bypassed, or "all" to test the handlers only`)
+
+var middlewares []func(http.Handler) http.Handler
+
+// RegisterMiddleware wraps the registered router with test-only middleware,
+// such as fault injection or request logging, in RunTest and StartServer.
+// The last middleware is the outermost.
+func RegisterMiddleware(mws ...func(http.Handler) http.Handler) {
+	middlewares = append(middlewares, mws...)
+}
+
+// WithMiddleware is like RegisterMiddleware for the Runner.
+func WithMiddleware(mws ...func(http.Handler) http.Handler) RunnerOption {
+	return func(rn *Runner) {
+		rn.middlewares = append(rn.middlewares, mws...)
+	}
+}
+
+// Bypassed reports whether the middleware name is bypassed by -bypass. It is
+// the hook for routers to strip their middleware, so that the same suite can
+// run in full stack and handler-only modes to localize failures:
+//
+//	func newRouter(bypassed func(name string) bool) http.Handler {
+//		var h http.Handler = mux
+//		if !bypassed("auth") {
+//			h = auth(h)
+//		}
+//		return h
+//	}
+//
+//	e2e.RegisterRouter(newRouter(e2e.Bypassed))
+func Bypassed(name string) bool {
+	names := strings.Split(*bypass, ",")
+	return slices.Contains(names, name) || slices.Contains(names, "all")
+}
+
+func wrap(h http.Handler, mws []func(http.Handler) http.Handler) http.Handler {
+	for _, mw := range mws {
+		h = mw(h)
+	}
+	return h
+}
@@ -0,0 +1,115 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// PartOption configures MultipartParts.
+type PartOption func(*partConfig)
+
+type partConfig struct {
+	filters map[string][]ResponseFilter
+}
+
+// PartFilters makes MultipartParts apply the filters to the parts of the
+// media type, e.g. "application/json", as if each part were a response of
+// its own. For application/http parts, the media type is that of the
+// embedded response.
+func PartFilters(mediaType string, filters ...ResponseFilter) PartOption {
+	return func(c *partConfig) {
+		c.filters[mediaType] = append(c.filters[mediaType], filters...)
+	}
+}
+
+// MultipartParts returns a ResponseFilter splitting a multipart response,
+// e.g. multipart/mixed of a batch API, into its parts, applying the filters
+// of PartFilters to each part by media type, and writing the parts in order
+// with their headers, so that the golden file holds the parts instead of
+// bodies delimited by random boundaries. Parts of application/http, as in
+// batch APIs, are parsed as the embedded HTTP responses, which are written
+// with their status lines. The boundary is removed from Content-Type.
+func MultipartParts(opts ...PartOption) ResponseFilter {
+	cfg := partConfig{filters: make(map[string][]ResponseFilter)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+			t.Fatal("Response is not multipart")
+		}
+
+		var out bytes.Buffer
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for n := 1; ; n++ {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("multipart part %d: %v\n", n, err)
+			}
+			part := &http.Response{
+				Status:     r.Status,
+				StatusCode: r.StatusCode,
+				Header:     http.Header(p.Header),
+				Body:       p,
+				Request:    r.Request,
+			}
+
+			// The filters apply to the embedded response of application/http
+			// parts, and the headers are written as they left them.
+			fmt.Fprintf(&out, "# part %d\n", n)
+			var embedded bool
+			if partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); partType == "application/http" {
+				if err := part.Header.Write(&out); err != nil {
+					t.Fatal(err)
+				}
+				out.WriteString("\n")
+				if part, err = http.ReadResponse(bufio.NewReader(p), r.Request); err != nil {
+					t.Fatalf("multipart part %d: %v\n", n, err)
+				}
+				part.Header.Del("Content-Length")
+				embedded = true
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			for _, f := range cfg.filters[partType] {
+				f(t, part)
+			}
+			if embedded {
+				fmt.Fprintf(&out, "%s %s\n", part.Proto, part.Status)
+			}
+			if err := part.Header.Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			out.WriteString("\n")
+
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				t.Fatalf("multipart part %d: %v\n", n, err)
+			}
+			out.Write(body)
+			if len(body) > 0 && !bytes.HasSuffix(body, []byte("\n")) {
+				out.WriteString("\n")
+			}
+		}
+
+		delete(params, "boundary")
+		r.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+		r.Body = io.NopCloser(&out)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package e2e
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile reports that files cannot be mapped into memory, so that they are
+// read instead.
+func mapFile(*os.File, int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package e2e
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f into memory read-only. The mapping
+// stays valid after f is closed.
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
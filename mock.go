@@ -0,0 +1,158 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// mockIndexName is the name of the file in testdata mapping requests to the
+// golden files of their responses, maintained by -golden for MockHandler.
+const mockIndexName = "mock.index"
+
+var mockIndex struct {
+	mu      sync.Mutex
+	entries map[string]string // golden file name to request key
+}
+
+// mockKey returns the key of r in the mock index: the method, path and
+// sorted query.
+func mockKey(r *http.Request) string {
+	key := r.Method + " " + r.URL.Path
+	if q := r.URL.Query(); len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+	return key
+}
+
+// recordMockEntry records that the golden file of name holds the response
+// to r.
+func recordMockEntry(name string, r *http.Request) {
+	mockIndex.mu.Lock()
+	defer mockIndex.mu.Unlock()
+
+	if mockIndex.entries == nil {
+		mockIndex.entries = make(map[string]string)
+	}
+	mockIndex.entries[name+".golden"] = mockKey(r)
+}
+
+// writeMockIndex merges the entries recorded by -golden into the mock
+// index, so that runs of a subset of the tests keep the other entries. Runs
+// of all the tests replace the index instead, dropping the entries of
+// removed tests, so that the index is the same as if built from scratch.
+func writeMockIndex() error {
+	mockIndex.mu.Lock()
+	defer mockIndex.mu.Unlock()
+
+	if len(mockIndex.entries) == 0 {
+		return nil
+	}
+	filename := filepath.Join("testdata", mockIndexName)
+	entries := make(map[string]string)
+	if !ranAllTests() {
+		prev, err := readMockIndex(filename)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		maps.Copy(entries, prev)
+	}
+	maps.Copy(entries, mockIndex.entries)
+
+	var buf bytes.Buffer
+	for _, golden := range slices.Sorted(maps.Keys(entries)) {
+		fmt.Fprintf(&buf, "%s\t%s\n", entries[golden], golden)
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o600)
+}
+
+// ranAllTests reports whether the tests were not filtered by -test.run or
+// -test.skip.
+func ranAllTests() bool {
+	for _, name := range []string{"test.run", "test.skip"} {
+		if f := flag.Lookup(name); f != nil && f.Value.String() != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func readMockIndex(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, golden, ok := strings.Cut(s.Text(), "\t")
+		if !ok {
+			return nil, fmt.Errorf("%s: malformed line %q", filename, s.Text())
+		}
+		entries[golden] = key
+	}
+	return entries, s.Err()
+}
+
+// MockHandler returns an http.Handler serving the responses recorded in the
+// golden files under dir, keyed by method, path and query, so that other
+// teams can run a faithful mock of the API derived from the suite. The
+// golden files are indexed by running the suite with -golden. When several
+// golden files hold responses to the same request, a successful one is
+// preferred. Requests matching no golden file get 404.
+func MockHandler(dir string) (http.Handler, error) {
+	entries, err := readMockIndex(filepath.Join(dir, mockIndexName))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[string]mockResponse)
+	for _, golden := range slices.Sorted(maps.Keys(entries)) {
+		data, err := os.ReadFile(filepath.Join(dir, golden))
+		if err != nil {
+			return nil, err
+		}
+		if data, _, err = decodeGolden(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", golden, err)
+		}
+		code, header, body, ok := parseDump(data)
+		if !ok {
+			return nil, fmt.Errorf("%s: malformed golden file", golden)
+		}
+		key := entries[golden]
+		if prev, ok := responses[key]; ok && (prev.code < 300 || code >= 300) {
+			continue
+		}
+		header.Del("Connection")
+		responses[key] = mockResponse{code: code, header: header, body: body}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[mockKey(r)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		maps.Copy(w.Header(), resp.header)
+		w.WriteHeader(resp.code)
+		_, _ = w.Write(resp.body)
+	}), nil
+}
+
+type mockResponse struct {
+	code   int
+	header http.Header
+	body   []byte
+}
@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// multipartBoundary is the boundary of the bodies built by MultipartBody,
+// fixed so that requests and the responses echoing them are stable across
+// runs.
+const multipartBoundary = "e2e-multipart-boundary"
+
+// MultipartFile is a file of a multipart/form-data body.
+type MultipartFile struct {
+	// Field is the name of the form field.
+	Field string
+	// Filename is the name of the file sent, defaulting to the base of Path.
+	Filename string
+	// Path is the file read when Content is nil.
+	Path string
+	// Content is the content of the file.
+	Content []byte
+	// ContentType defaults to application/octet-stream.
+	ContentType string
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// MultipartBody builds a multipart/form-data Body of the fields, sorted by
+// name, followed by the files. Its boundary is fixed, so the body is the
+// same on every run.
+func MultipartBody(t *testing.T, fields map[string]string, files ...MultipartFile) io.Reader {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	if err := w.SetBoundary(multipartBoundary); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		if err := w.WriteField(name, fields[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		content := f.Content
+		if content == nil {
+			var err error
+			if content, err = os.ReadFile(f.Path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		filename := f.Filename
+		if filename == "" {
+			filename = filepath.Base(f.Path)
+		}
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.Field), quoteEscaper.Replace(filename)))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(body.Bytes(), []byte(multipartBoundary)) != len(fields)+len(files)+1 {
+		t.Fatalf("MultipartBody: content contains the boundary %q\n", multipartBoundary)
+	}
+	return typedBody{Buffer: body, contentType: w.FormDataContentType()}
+}
@@ -0,0 +1,150 @@
+package e2e
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+)
+
+var (
+	runIDFlag = flag.String("run-id", "", "ID of this run of the suite (default $E2E_RUN_ID or random)")
+	runID     = newRunID()
+
+	runIDHeader = "X-E2E-Run-ID"
+)
+
+func newRunID() string {
+	if id := os.Getenv("E2E_RUN_ID"); id != "" {
+		return id
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RunID returns the ID of this run of the suite. NewRequest sends it in the
+// X-E2E-Run-ID header, so that the logs and traces of the system under test
+// can be filtered to a single CI execution.
+func RunID() string {
+	if *runIDFlag != "" {
+		return *runIDFlag
+	}
+	return runID
+}
+
+// SetRunIDHeader changes the header NewRequest sends RunID in. An empty
+// name disables the header.
+func SetRunIDHeader(name string) {
+	runIDHeader = name
+}
+
+// Namespace returns the prefix unique to this run of the suite. Names of
+// resources created against a shared environment should start with it, so
+// that parallel runs do not collide and leftovers can be collected.
+func Namespace() string {
+	return "e2e-" + RunID() + "-"
+}
+
+// Unique returns name prefixed with Namespace.
+func Unique(name string) string {
+	return Namespace() + name
+}
+
+// WithNamespace prefixes the string values of the top-level fields of the
+// JSON request body with Namespace. Requests without a body or with a body
+// which is not a JSON object are left as is.
+func WithNamespace(fields ...string) RequestOption {
+	return func(r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			panic(fmt.Sprintf("e2e: WithNamespace: read body: %v", err))
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			return
+		}
+		for _, f := range fields {
+			if s, ok := body[f].(string); ok {
+				body[f] = Unique(s)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(&body); err != nil {
+			panic(fmt.Sprintf("e2e: WithNamespace: encode JSON body: %v", err))
+		}
+		r.Body = io.NopCloser(buf)
+		r.ContentLength = int64(buf.Len())
+	}
+}
+
+// namespaceFields are the fields of the request bodies RunTest prefixes with
+// Namespace.
+var namespaceFields []string
+
+// RegisterNamespaceFields makes RunTest apply WithNamespace with fields to
+// every request, and replace Namespace in the response bodies with
+// "e2e-{run-id}-", so that tests run against a shared environment create
+// resources of this run while their golden files stay the same across runs.
+func RegisterNamespaceFields(fields ...string) {
+	namespaceFields = append(namespaceFields, fields...)
+}
+
+// WithNamespaceFields is like RegisterNamespaceFields for the Runner.
+func WithNamespaceFields(fields ...string) RunnerOption {
+	return func(rn *Runner) {
+		rn.namespaceFields = append(rn.namespaceFields, fields...)
+	}
+}
+
+// maskNamespace is a ResponseFilter replacing Namespace in the body with a
+// placeholder independent of the run.
+func maskNamespace(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	body := readBody(t, r)
+	r.Body = io.NopCloser(bytes.NewReader(bytes.ReplaceAll(body, []byte(Namespace()), []byte("e2e-{run-id}-"))))
+}
+
+// GarbageCollector deletes the resources whose names start with prefix.
+type GarbageCollector func(prefix string) error
+
+var garbageCollectors struct {
+	mu  sync.Mutex
+	gcs []GarbageCollector
+}
+
+// RegisterGarbageCollector registers gc to run with Namespace at the end of
+// RunSuite, removing the resources of this run that tests failed to clean up.
+func RegisterGarbageCollector(gc GarbageCollector) {
+	garbageCollectors.mu.Lock()
+	defer garbageCollectors.mu.Unlock()
+
+	garbageCollectors.gcs = append(garbageCollectors.gcs, gc)
+}
+
+func collectGarbage() error {
+	garbageCollectors.mu.Lock()
+	defer garbageCollectors.mu.Unlock()
+
+	var errs []error
+	for _, gc := range garbageCollectors.gcs {
+		if err := gc(Namespace()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type goldenByRequestKey struct{}
+
+// GoldenByRequest names the golden file of the request after its method,
+// path and response status followed by the descriptions, e.g.
+// testdata/GET_v1_user_1_200_new.golden, instead of the name of the test,
+// so that renaming test functions does not orphan their golden files. Two
+// tests writing the same golden file fail.
+func GoldenByRequest(description ...string) RequestOption {
+	description = append([]string{}, description...)
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), goldenByRequestKey{}, description))
+	}
+}
+
+// WithGoldenByRequest makes the Runner name the golden files of every
+// request like GoldenByRequest without descriptions.
+func WithGoldenByRequest() RunnerOption {
+	return func(rn *Runner) {
+		rn.goldenByRequest = true
+	}
+}
+
+// goldenNameOf returns the name of the golden file of the response to r of
+// status.
+func goldenNameOf(t *testing.T, rn *Runner, r *http.Request, status int) string {
+	description, ok := r.Context().Value(goldenByRequestKey{}).([]string)
+	if !ok && !rn.goldenByRequest {
+		return t.Name()
+	}
+
+	parts := []string{r.Method}
+	for _, seg := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if seg != "" {
+			parts = append(parts, seg)
+		}
+	}
+	parts = append(parts, strconv.Itoa(status))
+	parts = append(parts, description...)
+	return strings.Map(func(c rune) rune {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '.':
+			return c
+		}
+		return '_'
+	}, strings.Join(parts, "_"))
+}
@@ -0,0 +1,267 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NDJSONOption configures RunNDJSON.
+type NDJSONOption func(*ndjsonConfig)
+
+type ndjsonConfig struct {
+	bucket time.Duration
+}
+
+// TimingBuckets makes RunNDJSON record when each record was emitted, as the
+// time since the response started truncated to a multiple of d, e.g. 100ms
+// for a stream emitting a record every 100ms. d must be coarse enough for
+// the golden file to be stable.
+func TimingBuckets(d time.Duration) NDJSONOption {
+	return func(c *ndjsonConfig) {
+		c.bucket = d
+	}
+}
+
+// RunNDJSON sends r to the router and checks the status code, then compares
+// the headers and the records of the NDJSON stream it responds with the
+// golden file, or updates it with -golden. Each record is written with its
+// order and the flush which emitted it, so that the streaming behavior is
+// verified as well as the content.
+func RunNDJSON(t *testing.T, r *http.Request, want int, opts ...NDJSONOption) {
+	t.Helper()
+
+	defaultRunner().RunNDJSON(t, r, want, opts...)
+}
+
+// RunNDJSON is like RunNDJSON but sends r with rn. Over a socket or to a live
+// server, the flushes of the handler cannot be told apart from the network
+// buffering, so records are written without them, and TimingBuckets measure
+// when the records were received.
+func (rn *Runner) RunNDJSON(t *testing.T, r *http.Request, want int, opts ...NDJSONOption) {
+	t.Helper()
+
+	var cfg ndjsonConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t.Logf(">>> %s %s\n", r.Method, r.URL)
+
+	w := &ndjsonWriter{header: make(http.Header)}
+	if c := rn.client(t, r); c != nil {
+		w.receive(t, c, r)
+	} else {
+		rn.handlerFor(t, r).ServeHTTP(w, r)
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", w.code, http.StatusText(w.code))
+	if err := w.header.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("\r\n")
+
+	var n int
+	var partial string
+	for i, c := range w.chunks {
+		lines := strings.Split(partial+string(c.data), "\n")
+		// The last line is incomplete until a later chunk ends it.
+		partial = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n++
+			fmt.Fprintf(&buf, "# record %d", n)
+			if !w.received {
+				fmt.Fprintf(&buf, ", flush %d", i+1)
+			}
+			if cfg.bucket > 0 {
+				fmt.Fprintf(&buf, ", after %s", c.at.Sub(w.start).Truncate(cfg.bucket))
+			}
+			fmt.Fprintf(&buf, "\n%s\n", line)
+		}
+	}
+	if strings.TrimSpace(partial) != "" {
+		fmt.Fprintf(&buf, "# incomplete record\n%s\n", partial)
+	}
+
+	var failures []string
+	if w.code != want {
+		failures = append(failures, fmt.Sprintf("HTTP StatusCode: %d, want: %d\n", w.code, want))
+	}
+	if mismatch := compareGolden(t, buf.Bytes()); mismatch != "" {
+		failures = append(failures, "Records "+mismatch)
+	}
+	t.Logf("<<< %s\n", goldenFileName(t.Name()))
+	reportFailures(t, failures)
+}
+
+// ndjsonChunk is the data written by the handler between flushes.
+type ndjsonChunk struct {
+	data []byte
+	at   time.Time
+}
+
+// ndjsonWriter records the body in chunks ended by the flushes of the
+// handler, which httptest.ResponseRecorder does not keep apart.
+type ndjsonWriter struct {
+	header  http.Header
+	code    int
+	start   time.Time
+	pending []byte
+	chunks  []ndjsonChunk
+	// received reports whether the chunks are the reads of a response
+	// received over the network rather than flushes.
+	received bool
+}
+
+func (w *ndjsonWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *ndjsonWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	w.start = time.Now()
+}
+
+func (w *ndjsonWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.pending = append(w.pending, p...)
+	return len(p), nil
+}
+
+func (w *ndjsonWriter) Flush() {
+	if len(w.pending) == 0 {
+		return
+	}
+	w.chunks = append(w.chunks, ndjsonChunk{data: w.pending, at: time.Now()})
+	w.pending = nil
+}
+
+// receive records the response to r sent by c, with a chunk per read of the
+// body.
+func (w *ndjsonWriter) receive(t *testing.T, c *Client, r *http.Request) {
+	t.Helper()
+
+	resp, err := c.open(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	w.header = resp.Header
+	w.received = true
+	w.WriteHeader(resp.StatusCode)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			_, _ = w.Write(buf[:n])
+			w.Flush()
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ndjsonMediaTypes are the media types of line-delimited JSON.
+var ndjsonMediaTypes = map[string]bool{
+	"application/x-ndjson":     true,
+	"application/ndjson":       true,
+	"application/jsonl":        true,
+	"application/x-jsonlines":  true,
+	"application/jsonlines":    true,
+	"application/json-lines":   true,
+	"application/x-json-lines": true,
+}
+
+func isNDJSON(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err == nil && ndjsonMediaTypes[mediaType]
+}
+
+// PrettyNDJSON is a ResponseFilter for formatting NDJSON (JSON Lines)
+// responses. Each record is written on its own line with sorted keys and
+// without insignificant spaces, and blank lines are dropped, so that the
+// golden file keeps one record per line and its diffs show the records that
+// changed.
+func PrettyNDJSON(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	NDJSONRecords()(t, r)
+}
+
+// NDJSONRecords returns a ResponseFilter applying the JSON filters, e.g.
+// Mask, ModifyJSON and IgnoreFields, to every record of an NDJSON (JSON
+// Lines) response as if it were a JSON response, then formatting the records
+// like PrettyNDJSON. Filters formatting the body, such as PrettyJSON, are
+// undone, since records stay on one line.
+func NDJSONRecords(filters ...ResponseFilter) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		if !isNDJSON(r.Header.Get("Content-Type")) {
+			t.Fatal("Response is not NDJSON")
+		}
+
+		var out bytes.Buffer
+		sc := bufio.NewScanner(r.Body)
+		sc.Buffer(nil, 16<<20)
+		for n := 1; sc.Scan(); {
+			line := bytes.TrimSpace(sc.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			record := &http.Response{
+				Status:     r.Status,
+				StatusCode: r.StatusCode,
+				Header:     r.Header.Clone(),
+				Body:       io.NopCloser(bytes.NewReader(bytes.Clone(line))),
+				Request:    r.Request,
+			}
+			record.Header.Set("Content-Type", "application/json")
+			for _, f := range filters {
+				f(t, record)
+			}
+
+			var v any
+			dec := json.NewDecoder(record.Body)
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("NDJSON record %d: %v\n", n, err)
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Write(data)
+			out.WriteByte('\n')
+			n++
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(&out)
+	}
+}
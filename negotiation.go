@@ -0,0 +1,303 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// NegotiationCorpus is the malformed and exotic media types sent by
+// CheckNegotiation: wildcards, q-values, parameters and invalid tokens.
+var NegotiationCorpus = []string{
+	"",
+	"*/*",
+	"*",
+	"application/*",
+	"*/json",
+	"application/json;q=0",
+	"application/json;q=0.5, text/html;q=0.9",
+	"application/json;q=abc",
+	"application/json;q=-1",
+	"application/json;q=1.0001",
+	"application/json; charset=bogus",
+	"APPLICATION/JSON",
+	"application/json, application/json, application/json",
+	"application/vnd.example+json; version=99",
+	"text/plain",
+	"image/png",
+	"application",
+	"application/",
+	"/json",
+	"a/b/c",
+	";;;",
+	",,,",
+	"application/json;;",
+	"application/json; =value",
+	"application/json\x00",
+	"é/é",
+	strings.Repeat("text/html, ", 256),
+}
+
+// CheckNegotiation sends the request built by newRequest with each value of
+// NegotiationCorpus as Accept, then as Content-Type, hardening content
+// negotiation code. The handler must never respond 5xx, and error responses
+// must have a parseable body: valid JSON for JSON media types and non-empty
+// otherwise.
+func CheckNegotiation(t *testing.T, newRequest func(t *testing.T) *http.Request) {
+	t.Helper()
+
+	defaultRunner().CheckNegotiation(t, newRequest)
+}
+
+// CheckNegotiation is like CheckNegotiation but sends the requests with rn.
+func (rn *Runner) CheckNegotiation(t *testing.T, newRequest func(t *testing.T) *http.Request) {
+	t.Helper()
+
+	var send func(*http.Request) (*http.Response, error)
+	for _, header := range []string{"Accept", "Content-Type"} {
+		for _, value := range NegotiationCorpus {
+			r := newRequest(t)
+			r.Header.Set(header, value)
+			if send == nil {
+				send = rn.sender(t, r)
+			}
+			got, err := send(r)
+			if err != nil {
+				t.Errorf("%s: %q: %v\n", header, value, err)
+				continue
+			}
+
+			if got.StatusCode >= http.StatusInternalServerError {
+				t.Errorf("%s: %q: HTTP StatusCode: %d\n", header, value, got.StatusCode)
+				continue
+			}
+			if got.StatusCode >= http.StatusBadRequest {
+				if err := parseableError(got, readBody(t, got)); err != "" {
+					t.Errorf("%s: %q: HTTP StatusCode: %d: %s\n", header, value, got.StatusCode, err)
+				}
+			}
+		}
+	}
+}
+
+// parseableError returns why the error response r with body is not
+// parseable, or an empty string.
+func parseableError(r *http.Response, body []byte) string {
+	if len(body) == 0 && (r.StatusCode == http.StatusNotAcceptable || r.StatusCode == http.StatusUnsupportedMediaType) {
+		// The body is optional when the failure is the negotiation itself.
+		return ""
+	}
+	if len(body) == 0 {
+		return "empty error body"
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt == "application/json" || strings.HasSuffix(mt, "+json") {
+		if !json.Valid(body) {
+			return "invalid JSON error body"
+		}
+	}
+	return ""
+}
+
+// Negotiation is the representations an endpoint supports, which
+// CheckNotAcceptable derives the unsupported Accept values from.
+type Negotiation struct {
+	// Pattern matches the requests to the endpoint in the syntax of
+	// http.ServeMux, e.g. "GET /v1/user/{id}".
+	Pattern string
+	// Types are the media types the endpoint responds with, e.g.
+	// "application/json".
+	Types []string
+	// Charsets are the charsets the endpoint encodes text in, e.g. "utf-8".
+	// Accept-Charset is not checked when empty.
+	Charsets []string
+	// Path is the path CheckRegisteredNotAcceptable sends the requests to,
+	// e.g. "/v1/user/1". It defaults to the path of Pattern, which must then
+	// have no wildcards.
+	Path string
+}
+
+var negotiations struct {
+	mu  sync.Mutex
+	mux *http.ServeMux
+	m   map[string]Negotiation
+}
+
+// RegisterNegotiation registers the representations an endpoint supports for
+// CheckNotAcceptable and CheckRegisteredNotAcceptable.
+func RegisterNegotiation(n Negotiation) {
+	negotiations.mu.Lock()
+	defer negotiations.mu.Unlock()
+
+	if negotiations.mux == nil {
+		negotiations.mux = http.NewServeMux()
+		negotiations.m = make(map[string]Negotiation)
+	}
+	negotiations.mux.Handle(n.Pattern, http.NotFoundHandler())
+	negotiations.m[n.Pattern] = n
+}
+
+// negotiationOf returns the negotiation of the endpoint r is sent to.
+func negotiationOf(r *http.Request) (Negotiation, bool) {
+	negotiations.mu.Lock()
+	defer negotiations.mu.Unlock()
+
+	if negotiations.mux == nil {
+		return Negotiation{}, false
+	}
+	_, pattern := negotiations.mux.Handler(r)
+	n, ok := negotiations.m[pattern]
+	return n, ok
+}
+
+// unsupportedType is a media type no endpoint supports.
+const unsupportedType = "x-e2e-unsupported"
+
+// notAcceptableHeaders returns the Accept and Accept-Charset values which
+// the endpoint of n cannot satisfy: unsupported types of the top-level
+// types it supports, its types refused with q=0, and an unsupported charset.
+func notAcceptableHeaders(n Negotiation) []http.Header {
+	var headers []http.Header
+	add := func(key, value string) {
+		h := make(http.Header)
+		h.Set(key, value)
+		if key == "Accept-Charset" {
+			h.Set("Accept", n.Types[0])
+		}
+		headers = append(headers, h)
+	}
+
+	tops := []string{"application"}
+	var refused []string
+	for _, typ := range n.Types {
+		top, _, _ := strings.Cut(typ, "/")
+		if !slices.Contains(tops, top) {
+			tops = append(tops, top)
+		}
+		refused = append(refused, typ+";q=0")
+	}
+	for _, top := range tops {
+		add("Accept", top+"/"+unsupportedType)
+	}
+	add("Accept", strings.Join(append(refused, "application/"+unsupportedType), ", "))
+	if len(n.Charsets) > 0 {
+		add("Accept-Charset", unsupportedType)
+	}
+	return headers
+}
+
+// CheckNotAcceptable sends the request built by newRequest with the Accept
+// and Accept-Charset values its endpoint, registered with
+// RegisterNegotiation, cannot satisfy, generated from its supported types
+// and charsets. Each must be answered with 406 Not Acceptable and a body
+// listing every supported type, so that negotiation failures are consistent
+// across endpoints and tell clients what to ask for instead.
+func CheckNotAcceptable(t *testing.T, newRequest func(t *testing.T) *http.Request) {
+	t.Helper()
+
+	defaultRunner().CheckNotAcceptable(t, newRequest)
+}
+
+// CheckNotAcceptable is like CheckNotAcceptable but sends the requests with
+// rn.
+func (rn *Runner) CheckNotAcceptable(t *testing.T, newRequest func(t *testing.T) *http.Request) {
+	t.Helper()
+
+	n, ok := negotiationOf(newRequest(t))
+	if !ok || len(n.Types) == 0 {
+		fatalf(t, "CheckNotAcceptable: no Negotiation with types is registered for the endpoint")
+	}
+	rn.checkNotAcceptable(t, n, newRequest)
+}
+
+// CheckRegisteredNotAcceptable is like CheckNotAcceptable for every endpoint
+// registered with RegisterNegotiation, each in a subtest named by its
+// pattern, so that the checks follow the suite config without a call per
+// endpoint. The requests are sent to the Path of the Negotiation.
+func CheckRegisteredNotAcceptable(t *testing.T) {
+	t.Helper()
+
+	defaultRunner().CheckRegisteredNotAcceptable(t)
+}
+
+// CheckRegisteredNotAcceptable is like CheckRegisteredNotAcceptable but
+// sends the requests with rn.
+func (rn *Runner) CheckRegisteredNotAcceptable(t *testing.T) {
+	t.Helper()
+
+	negotiations.mu.Lock()
+	registered := slices.SortedFunc(maps.Values(negotiations.m), func(a, b Negotiation) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	negotiations.mu.Unlock()
+	if len(registered) == 0 {
+		fatalf(t, "CheckRegisteredNotAcceptable: no Negotiation is registered")
+	}
+
+	for _, n := range registered {
+		t.Run(n.Pattern, func(t *testing.T) {
+			method, path, ok := strings.Cut(n.Pattern, " ")
+			if !ok {
+				method, path = http.MethodGet, n.Pattern
+			}
+			if n.Path != "" {
+				path = n.Path
+			}
+			if len(n.Types) == 0 {
+				fatalf(t, "CheckRegisteredNotAcceptable: the Negotiation of %s has no types", n.Pattern)
+			}
+			if strings.Contains(path, "{") || !strings.HasPrefix(path, "/") {
+				fatalf(t, "CheckRegisteredNotAcceptable: the Negotiation of %s needs a Path to send requests to", n.Pattern)
+			}
+			rn.checkNotAcceptable(t, n, func(*testing.T) *http.Request {
+				return NewRequest(method, path, nil)
+			})
+		})
+	}
+}
+
+// checkNotAcceptable sends the requests of CheckNotAcceptable for the
+// endpoint of n, reporting the failures with reportFailures, so that XFail
+// and quarantine apply to them.
+func (rn *Runner) checkNotAcceptable(t *testing.T, n Negotiation, newRequest func(t *testing.T) *http.Request) {
+	t.Helper()
+
+	var failures []string
+	var send func(*http.Request) (*http.Response, error)
+	for _, h := range notAcceptableHeaders(n) {
+		r := newRequest(t)
+		for k, v := range h {
+			r.Header[k] = v
+		}
+		key := "Accept"
+		if h.Get("Accept-Charset") != "" {
+			key = "Accept-Charset"
+		}
+		if send == nil {
+			send = rn.sender(t, r)
+		}
+		got, err := send(r)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %q: %v\n", key, h.Get(key), err))
+			continue
+		}
+
+		if got.StatusCode != http.StatusNotAcceptable {
+			failures = append(failures, fmt.Sprintf("%s: %q: HTTP StatusCode: %d, want: %d\n", key, h.Get(key), got.StatusCode, http.StatusNotAcceptable))
+			continue
+		}
+		body := string(readBody(t, got))
+		for _, typ := range n.Types {
+			if !strings.Contains(body, typ) {
+				failures = append(failures, fmt.Sprintf("%s: %q: the body of the 406 response does not list the supported type %s\n", key, h.Get(key), typ))
+			}
+		}
+	}
+	reportFailures(t, failures)
+}
@@ -0,0 +1,356 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// openAPIMethods are the operations of an OpenAPI path item in the order
+// tests are generated.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type openAPIDocument struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type openAPIOperation struct {
+	OperationID string             `json:"operationId"`
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]openAPIMediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]openAPIMediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name    string          `json:"name"`
+	In      string          `json:"in"`
+	Example json.RawMessage `json:"example"`
+}
+
+type openAPIMediaType struct {
+	Example  json.RawMessage `json:"example"`
+	Examples map[string]struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"examples"`
+}
+
+// examples returns the examples of m by name, with the single example
+// named "example".
+func (m openAPIMediaType) examples() map[string]json.RawMessage {
+	examples := make(map[string]json.RawMessage)
+	if m.Example != nil {
+		examples["example"] = m.Example
+	}
+	for name, e := range m.Examples {
+		if e.Value != nil {
+			examples[name] = e.Value
+		}
+	}
+	return examples
+}
+
+// generatedTest is a test function generated for an operation.
+type generatedTest struct {
+	Name string
+	// Method is the name of the method in the http.Method constants, e.g.
+	// "Get".
+	Method string
+	Path   string
+	Cases  []generatedCase
+}
+
+type generatedCase struct {
+	Name string
+	Body string
+	Want int
+	JSON bool
+
+	// response is the example of the response body.
+	response json.RawMessage
+}
+
+var generatedTestTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"quote": func(s string) string {
+		if strconv.CanBackquote(s) {
+			return "`" + s + "`"
+		}
+		return strconv.Quote(s)
+	},
+}).Parse(`// Code generated by e2egen from {{.Spec}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/satorunooshie/e2e"
+)
+{{range .Tests}}
+func {{.Name}}(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		filters []e2e.ResponseFilter
+	}{
+	{{- range .Cases}}
+		{
+			name: {{printf "%q" .Name}},
+			{{- if .Body}}
+			body: {{quote .Body}},
+			{{- end}}
+			want: {{.Want}},
+			{{- if .JSON}}
+			filters: []e2e.ResponseFilter{e2e.PrettyJSON},
+			{{- end}}
+		},
+	{{- end}}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			var opts []e2e.RequestOption
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+				opts = append(opts, e2e.WithContentType("application/json"))
+			}
+			r := e2e.NewRequest(http.Method{{.Method}}, {{printf "%q" .Path}}, body, opts...)
+			e2e.RunTest(t, r, tt.want, tt.filters...)
+		})
+	}
+}
+{{end}}`))
+
+// GenerateTests reads the OpenAPI 3 document in JSON at spec, and writes
+// table-driven tests of package pkg to openapi_test.go under dir, with a case
+// per example of the JSON responses of every operation. The request body of a
+// case is the request example of the same name, or the only one. Path
+// parameters are filled with their examples. The response examples are
+// written as golden files under dir/testdata, unless they already exist, so
+// that the suite of a spec-first API is bootstrapped from the spec and then
+// maintained with -golden.
+func GenerateTests(spec, dir, pkg string) error {
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return err
+	}
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", spec, err)
+	}
+
+	var tests []generatedTest
+	goldens := make(map[string][]byte)
+	for _, path := range slices.Sorted(maps.Keys(doc.Paths)) {
+		item := doc.Paths[path]
+		var common []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &common); err != nil {
+				return fmt.Errorf("%s: parameters: %w", path, err)
+			}
+		}
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			test, err := generateTest(method, path, op, append(slices.Clip(common), op.Parameters...))
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if len(test.Cases) == 0 {
+				continue
+			}
+			tests = append(tests, test)
+		}
+	}
+
+	for _, test := range tests {
+		for _, c := range test.Cases {
+			golden := filepath.Join(dir, goldenFileName(test.Name+"/"+c.Name))
+			if _, ok := goldens[golden]; ok {
+				return fmt.Errorf("%s: generated twice", golden)
+			}
+			dump, err := seedDump(c)
+			if err != nil {
+				return fmt.Errorf("%s: %w", golden, err)
+			}
+			goldens[golden] = encodeGolden(dump)
+		}
+	}
+
+	var src bytes.Buffer
+	if err := generatedTestTemplate.Execute(&src, map[string]any{
+		"Spec":    filepath.Base(spec),
+		"Package": pkg,
+		"Tests":   tests,
+	}); err != nil {
+		return err
+	}
+	code, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "openapi_test.go"), code, 0o644); err != nil {
+		return err
+	}
+	for _, golden := range slices.Sorted(maps.Keys(goldens)) {
+		if _, err := os.Stat(golden); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(golden, goldens[golden], 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateTest returns the test of the operation op of method and path.
+func generateTest(method, path string, op openAPIOperation, params []openAPIParameter) (generatedTest, error) {
+	test := generatedTest{Name: "Test" + exportedName(op.OperationID), Method: exportedName(method)}
+	if op.OperationID == "" {
+		test.Name = "Test" + exportedName(method+" "+path)
+	}
+
+	for _, p := range params {
+		if p.In != "path" {
+			continue
+		}
+		if p.Example == nil {
+			return test, fmt.Errorf("path parameter %q has no example", p.Name)
+		}
+		var v any
+		if err := json.Unmarshal(p.Example, &v); err != nil {
+			return test, fmt.Errorf("path parameter %q: %w", p.Name, err)
+		}
+		path = strings.ReplaceAll(path, "{"+p.Name+"}", fmt.Sprint(v))
+	}
+	test.Path = path
+
+	var requests map[string]json.RawMessage
+	if op.RequestBody != nil {
+		requests = op.RequestBody.Content["application/json"].examples()
+	}
+	for _, status := range slices.Sorted(maps.Keys(op.Responses)) {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			// Ranges like 4XX and default have no status to expect.
+			continue
+		}
+		content := op.Responses[status].Content
+		if len(content) == 0 {
+			if len(requests) > 1 {
+				for _, name := range slices.Sorted(maps.Keys(requests)) {
+					test.Cases = append(test.Cases, generatedCase{Name: caseName(status, name), Body: compactJSON(requests[name]), Want: code})
+				}
+				continue
+			}
+			c := generatedCase{Name: status, Want: code}
+			for _, body := range requests {
+				c.Body = compactJSON(body)
+			}
+			test.Cases = append(test.Cases, c)
+			continue
+		}
+		responses := content["application/json"].examples()
+		for _, name := range slices.Sorted(maps.Keys(responses)) {
+			c := generatedCase{Name: caseName(status, name), Want: code, JSON: true, response: responses[name]}
+			if body, ok := requests[name]; ok {
+				c.Body = compactJSON(body)
+			} else if len(requests) == 1 {
+				for _, body := range requests {
+					c.Body = compactJSON(body)
+				}
+			}
+			test.Cases = append(test.Cases, c)
+		}
+	}
+	return test, nil
+}
+
+// seedDump returns the response dump of the case c as RunTest writes it
+// with PrettyJSON.
+func seedDump(c generatedCase) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\nConnection: close\r\n", c.Want, http.StatusText(c.Want))
+	if c.response == nil {
+		buf.WriteString("\r\n")
+		return buf.Bytes(), nil
+	}
+	buf.WriteString("Content-Type: application/json\r\n\r\n")
+	var v any
+	if err := json.Unmarshal(c.response, &v); err != nil {
+		return nil, err
+	}
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// compactJSON returns the example data without insignificant spaces.
+func compactJSON(data json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
+
+// exportedName returns s, e.g. "getUser" or "get /v1/user/{id}", as an
+// exported Go identifier, e.g. "GetUser" or "GetV1UserId".
+func exportedName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	return b.String()
+}
+
+// caseName returns the name of the example as the name of a subtest, which
+// is also the name of its golden file.
+func caseName(status, example string) string {
+	return status + "_" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, example)
+}
@@ -0,0 +1,185 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// paginationRels are the relations of pagination links in the order they
+// are written back to the Link header.
+var paginationRels = []string{"first", "prev", "next", "last"}
+
+// PaginationOption configures PaginationLinks.
+type PaginationOption func(*paginationConfig)
+
+type paginationConfig struct {
+	required []string
+	cursors  []string
+}
+
+// RequireRels makes PaginationLinks fail when the Link header lacks any of
+// the relations, e.g. "first" and "last" for APIs with page numbers.
+func RequireRels(rels ...string) PaginationOption {
+	return func(c *paginationConfig) {
+		c.required = append(c.required, rels...)
+	}
+}
+
+// CursorParams sets the query parameters holding opaque cursor tokens,
+// "cursor", "page_token", "after" and "before" by default.
+func CursorParams(names ...string) PaginationOption {
+	return func(c *paginationConfig) {
+		c.cursors = names
+	}
+}
+
+// paginationLink is a link of an RFC 8288 Link header.
+type paginationLink struct {
+	URL    string
+	Rel    string
+	Params []string
+}
+
+// parseLinkHeader parses the RFC 8288 Link header values into links, one per
+// relation type of each link.
+func parseLinkHeader(values []string) ([]paginationLink, error) {
+	var links []paginationLink
+	for _, v := range values {
+		for _, field := range splitLinkHeader(v) {
+			target, rest, ok := strings.Cut(strings.TrimSpace(field), ">")
+			if !ok || !strings.HasPrefix(target, "<") {
+				return nil, fmt.Errorf("malformed link %q", field)
+			}
+			var rels, params []string
+			for _, p := range strings.Split(rest, ";") {
+				p = strings.TrimSpace(p)
+				if p == "" {
+					continue
+				}
+				name, value, _ := strings.Cut(p, "=")
+				if strings.EqualFold(strings.TrimSpace(name), "rel") {
+					rels = strings.Fields(strings.Trim(strings.TrimSpace(value), `"`))
+					continue
+				}
+				params = append(params, p)
+			}
+			if len(rels) == 0 {
+				return nil, fmt.Errorf("link %q has no rel", field)
+			}
+			for _, rel := range rels {
+				links = append(links, paginationLink{URL: target[1:], Rel: strings.ToLower(rel), Params: params})
+			}
+		}
+	}
+	return links, nil
+}
+
+// splitLinkHeader splits v at the commas separating links, which may also
+// appear within URLs and quoted parameters.
+func splitLinkHeader(v string) []string {
+	var fields []string
+	inURL, inQuote, start := false, false, 0
+	for i, c := range v {
+		switch {
+		case c == '<' && !inQuote:
+			inURL = true
+		case c == '>' && !inQuote:
+			inURL = false
+		case c == '"' && !inURL:
+			inQuote = !inQuote
+		case c == ',' && !inURL && !inQuote:
+			fields = append(fields, v[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(v[start:]) != "" {
+		fields = append(fields, v[start:])
+	}
+	return fields
+}
+
+// PaginationLinks returns a ResponseFilter validating the pagination links
+// of the Link header: every link must be a URL to the path of the request,
+// relations must not repeat, and the relations required by RequireRels must
+// be present. The header is rewritten in the order first, prev, next and
+// last, with the cursor tokens of CursorParams replaced by numbered
+// placeholders, so that golden files stay stable.
+func PaginationLinks(opts ...PaginationOption) ResponseFilter {
+	cfg := paginationConfig{cursors: []string{"cursor", "page_token", "after", "before"}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		links, err := parseLinkHeader(r.Header.Values("Link"))
+		if err != nil {
+			t.Fatalf("PaginationLinks: %v\n", err)
+		}
+		byRel := make(map[string]paginationLink)
+		var others []paginationLink
+		for _, l := range links {
+			if !slices.Contains(paginationRels, l.Rel) {
+				others = append(others, l)
+				continue
+			}
+			if _, ok := byRel[l.Rel]; ok {
+				errorf(t, r, "PaginationLinks: rel %q repeats\n", l.Rel)
+			}
+			byRel[l.Rel] = l
+		}
+		for _, rel := range cfg.required {
+			if _, ok := byRel[rel]; !ok {
+				errorf(t, r, "PaginationLinks: rel %q is missing\n", rel)
+			}
+		}
+
+		cursors := make(map[string]string)
+		var values []string
+		for _, rel := range paginationRels {
+			l, ok := byRel[rel]
+			if !ok {
+				continue
+			}
+			u, err := url.Parse(l.URL)
+			if err != nil {
+				errorf(t, r, "PaginationLinks: rel %q: %v\n", rel, err)
+				continue
+			}
+			if r.Request != nil && u.Path != r.Request.URL.Path {
+				errorf(t, r, "PaginationLinks: rel %q links to %s, want: %s\n", rel, u.Path, r.Request.URL.Path)
+			}
+			q := u.Query()
+			for _, name := range cfg.cursors {
+				if token := q.Get(name); token != "" {
+					if _, ok := cursors[token]; !ok {
+						cursors[token] = fmt.Sprintf("cursor-%d", len(cursors)+1)
+					}
+					q.Set(name, cursors[token])
+				}
+			}
+			u.RawQuery = q.Encode()
+			l.URL = u.String()
+			values = append(values, formatLink(l))
+		}
+		for _, l := range others {
+			values = append(values, formatLink(l))
+		}
+		if len(values) > 0 {
+			r.Header.Set("Link", strings.Join(values, ", "))
+		}
+	}
+}
+
+func formatLink(l paginationLink) string {
+	s := fmt.Sprintf(`<%s>; rel="%s"`, l.URL, l.Rel)
+	for _, p := range l.Params {
+		s += "; " + p
+	}
+	return s
+}
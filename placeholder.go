@@ -0,0 +1,66 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// goldenPlaceholder matches the placeholders of golden files.
+var goldenPlaceholder = regexp.MustCompile(`<<(ANY|UUID|RFC3339|RE:.*?)>>`)
+
+// placeholderPatterns are the patterns matched by the named placeholders.
+var placeholderPatterns = map[string]string{
+	"ANY":     `[^\n]*?`,
+	"UUID":    `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"RFC3339": `\d{4}-\d{2}-\d{2}[Tt]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:[Zz]|[+-]\d{2}:\d{2})`,
+}
+
+// hasPlaceholders reports whether the golden file has placeholders.
+func hasPlaceholders(golden []byte) bool {
+	return goldenPlaceholder.Match(golden)
+}
+
+// matchPlaceholders reports whether dump matches the golden file, in which
+// placeholders match volatile values instead of themselves: <<ANY>> matches
+// anything up to the end of the line, <<UUID>> a UUID, <<RFC3339>> an RFC
+// 3339 timestamp, and <<RE:pattern>> the regular expression pattern within
+// the line, e.g. <<RE:\d+>>.
+func matchPlaceholders(golden, dump []byte) (bool, error) {
+	var expr strings.Builder
+	expr.WriteString(`\A`)
+	last := 0
+	for _, m := range goldenPlaceholder.FindAllSubmatchIndex(golden, -1) {
+		expr.WriteString(regexp.QuoteMeta(string(golden[last:m[0]])))
+		name := string(golden[m[2]:m[3]])
+		if pattern, ok := strings.CutPrefix(name, "RE:"); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return false, fmt.Errorf("placeholder %s: %w", golden[m[0]:m[1]], err)
+			}
+			expr.WriteString("(?:" + pattern + ")")
+		} else {
+			expr.WriteString(placeholderPatterns[name])
+		}
+		last = m[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(string(golden[last:])))
+	expr.WriteString(`\z`)
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false, err
+	}
+	return re.Match(dump), nil
+}
+
+// equalGolden reports whether dump matches the golden file, exactly or with
+// its placeholders.
+func equalGolden(golden, dump []byte) (bool, error) {
+	if bytes.Equal(golden, dump) {
+		return true, nil
+	}
+	if !hasPlaceholders(golden) {
+		return false, nil
+	}
+	return matchPlaceholders(golden, dump)
+}
@@ -0,0 +1,68 @@
+package e2e
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+	"time"
+)
+
+var (
+	profileSlowFlag = flag.Duration("profile-slow", 0, "write CPU and heap profiles of in-process requests taking longer than the duration; 0 disables")
+	profileDirFlag  = flag.String("profile-dir", "profiles", "directory of the profiles written by -profile-slow")
+)
+
+// ProfileSlow makes the Runner write CPU and heap profiles of the requests
+// taking longer than threshold to dir, so that sporadically slow endpoints
+// come with profiling data attached. Only in-process requests are profiled.
+func ProfileSlow(threshold time.Duration, dir string) RunnerOption {
+	return func(rn *Runner) {
+		rn.profileThreshold = threshold
+		rn.profileDir = dir
+	}
+}
+
+// profile runs serve, writing profiles when it takes longer than the
+// threshold of rn. The CPU profile is skipped when another request is being
+// profiled, since only one CPU profile can run at a time.
+func (rn *Runner) profile(t *testing.T, serve func()) {
+	t.Helper()
+
+	if rn.profileThreshold <= 0 {
+		serve()
+		return
+	}
+
+	var cpu bytes.Buffer
+	cpuStarted := pprof.StartCPUProfile(&cpu) == nil
+	start := time.Now()
+	serve()
+	elapsed := time.Since(start)
+	if cpuStarted {
+		pprof.StopCPUProfile()
+	}
+	if elapsed <= rn.profileThreshold {
+		return
+	}
+
+	base := filepath.Join(rn.profileDir, t.Name())
+	if err := os.MkdirAll(filepath.Dir(base), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if cpuStarted {
+		if err := os.WriteFile(base+".cpu.pprof", cpu.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var heap bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&heap, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".heap.pprof", heap.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("request took %s, exceeding %s; profiles written to %s.*.pprof\n", elapsed, rn.profileThreshold, base)
+}
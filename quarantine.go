@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var quarantineFile = flag.String("quarantine", filepath.Join("testdata", "quarantine.txt"), "file listing quarantined tests")
+
+var quarantine struct {
+	once    sync.Once
+	err     error
+	reasons map[string]string
+
+	mu      sync.Mutex
+	results map[string]bool // test name -> failed
+}
+
+// loadQuarantine reads the quarantine file. Each line consists of a test
+// name followed by the reason, e.g. an issue ID. Subtests of a listed test
+// are quarantined too. Empty lines and lines starting with # are ignored:
+//
+//	# Upstream returns 500 intermittently.
+//	TestUserGetEndpoint/v1_user_500_exception JIRA-123
+func loadQuarantine() (map[string]string, error) {
+	f, err := os.Open(*quarantineFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reasons := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, reason, _ := strings.Cut(line, " ")
+		reasons[name] = strings.TrimSpace(reason)
+	}
+	return reasons, sc.Err()
+}
+
+// quarantined reports whether t is quarantined and the reason why. The
+// result is recorded for the quarantine report of RunSuite.
+func quarantined(t *testing.T) (string, bool) {
+	t.Helper()
+
+	quarantine.once.Do(func() {
+		quarantine.reasons, quarantine.err = loadQuarantine()
+		quarantine.results = make(map[string]bool)
+	})
+	if quarantine.err != nil {
+		t.Fatalf("load quarantine: %v", quarantine.err)
+	}
+
+	name := t.Name()
+	for {
+		if reason, ok := quarantine.reasons[name]; ok {
+			t.Cleanup(func() {
+				quarantine.mu.Lock()
+				defer quarantine.mu.Unlock()
+				quarantine.results[t.Name()] = quarantine.results[t.Name()] || t.Skipped() || t.Failed()
+			})
+			return reason, true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return "", false
+		}
+		name = name[:i]
+	}
+}
+
+func writeQuarantineReport(w io.Writer) {
+	quarantine.mu.Lock()
+	defer quarantine.mu.Unlock()
+
+	if len(quarantine.results) == 0 {
+		return
+	}
+	names := make([]string, 0, len(quarantine.results))
+	for name := range quarantine.results {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(w, "Quarantined tests:")
+	for _, name := range names {
+		status := "FAIL"
+		if !quarantine.results[name] {
+			status = "PASS (consider removing it from quarantine)"
+		}
+		fmt.Fprintf(w, "  %s: %s\n", name, status)
+	}
+}
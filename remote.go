@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// baseURLEnv names the environment variable holding the base URL of a live
+// server, e.g. a blue or green deployment, to which RunTest sends the
+// requests instead of calling the router.
+const baseURLEnv = "E2E_BASE_URL"
+
+var (
+	baseURL        = flag.String("base-url", "", "send the requests of RunTest to the live server at the URL instead of the router; defaults to $"+baseURLEnv)
+	remoteTimeout  = flag.Duration("remote-timeout", 30*time.Second, "timeout of the requests sent to the live server")
+	remoteInsecure = flag.Bool("remote-insecure", false, "skip verifying the certificate of the live server")
+)
+
+var remote struct {
+	once   sync.Once
+	client *Client
+}
+
+// remoteClient returns the Client sending requests to the live server set by
+// -base-url or $E2E_BASE_URL, or nil when none is set.
+func remoteClient() *Client {
+	remote.once.Do(func() {
+		base := *baseURL
+		if base == "" {
+			base = os.Getenv(baseURLEnv)
+		}
+		if base == "" {
+			return
+		}
+		var opts []RemoteOption
+		if *remoteInsecure {
+			opts = append(opts, RemoteTLS(&tls.Config{InsecureSkipVerify: true}))
+		}
+		remote.client = newRemoteClient(base, append(opts, RemoteTimeout(*remoteTimeout))...)
+	})
+	return remote.client
+}
+
+// RemoteOption configures the client of Remote.
+type RemoteOption func(*http.Client, *http.Transport)
+
+// RemoteTimeout limits the time of every request to the live server,
+// including reading the response body. The default is 30 seconds.
+func RemoteTimeout(d time.Duration) RemoteOption {
+	return func(c *http.Client, _ *http.Transport) {
+		c.Timeout = d
+	}
+}
+
+// RemoteTLS sets the TLS configuration, e.g. the root CAs of a staging
+// environment or client certificates.
+func RemoteTLS(cfg *tls.Config) RemoteOption {
+	return func(_ *http.Client, tr *http.Transport) {
+		tr.TLSClientConfig = cfg
+	}
+}
+
+// Remote makes the Runner send the requests to the live server at base, e.g.
+// a staging environment, instead of the handler, so that the same golden
+// files verify a deployment.
+func Remote(base string, opts ...RemoteOption) RunnerOption {
+	return func(rn *Runner) {
+		rn.remote = newRemoteClient(base, opts...)
+	}
+}
+
+func newRemoteClient(base string, opts ...RemoteOption) *Client {
+	u, err := url.Parse(base)
+	if err != nil {
+		panic(err)
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	c := &http.Client{Transport: tr, Timeout: 30 * time.Second}
+	for _, opt := range opts {
+		opt(c, tr)
+	}
+	return &Client{baseURL: u, client: c, remote: true}
+}
@@ -0,0 +1,272 @@
+package e2e
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	latencyReport     = flag.Bool("latency", false, "report latency per route at suite end")
+	latencyReportJSON = flag.String("latency-json", "", "write latency per route as JSON to the file at suite end")
+	resultsJSON       = flag.String("results-json", "", "append the result of every request as a JSON line to the file at suite end")
+)
+
+var suite struct {
+	mu       sync.Mutex
+	requests []requestRecord
+}
+
+type requestRecord struct {
+	test     string
+	method   string
+	route    string
+	status   int
+	duration time.Duration
+}
+
+func recordRequest(t *testing.T, r *http.Request, status int, d time.Duration) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.requests = append(suite.requests, requestRecord{test: t.Name(), method: r.Method, route: routeOf(r), status: status, duration: d})
+}
+
+// routeOf returns the pattern of the route which served r, or its path.
+func routeOf(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return r.URL.Path
+}
+
+// RunSuite runs the tests, releases the routers shared across tests, runs
+// the garbage collectors and writes the results of quarantined tests and the
+// reports enabled by flags. Call it
+// from TestMain instead of m.Run:
+//
+//	func TestMain(m *testing.M) {
+//		e2e.RegisterRouter(newRouter())
+//
+//		os.Exit(e2e.RunSuite(m))
+//	}
+func RunSuite(m *testing.M, opts ...SuiteOption) int {
+	var cfg suiteConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	start := time.Now()
+	code := m.Run()
+	elapsed := time.Since(start)
+	releaseRouters()
+	if err := collectGarbage(); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: garbage collection: %v\n", err)
+		code = 1
+	}
+
+	writeQuarantineReport(os.Stdout)
+	writeShuffleReport(os.Stdout)
+	writeDependencyReport(os.Stdout)
+	if writeGoldenAudit(os.Stdout) {
+		code = 1
+	}
+	if cfg.budget > 0 && elapsed > cfg.budget {
+		writeBudgetReport(os.Stdout, elapsed, cfg.budget)
+		if !cfg.warnOnly {
+			code = 1
+		}
+	}
+	if *latencyReport {
+		if err := writeLatencyReport(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+			code = 1
+		}
+	}
+	if err := writeMockIndex(); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		code = 1
+	}
+	if *goldenChanges != "" {
+		if err := writeGoldenChanges(*goldenChanges); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+			code = 1
+		}
+	}
+	if *resultsJSON != "" {
+		if err := appendResults(*resultsJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+			code = 1
+		}
+	}
+	if *latencyReportJSON != "" {
+		if err := writeLatencyJSON(*latencyReportJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// SuiteOption configures RunSuite.
+type SuiteOption func(*suiteConfig)
+
+type suiteConfig struct {
+	budget   time.Duration
+	warnOnly bool
+}
+
+// WithBudget makes RunSuite fail when the suite takes longer than budget,
+// listing the tests that took the longest, to keep runtime creep in check.
+func WithBudget(budget time.Duration) SuiteOption {
+	return func(c *suiteConfig) {
+		c.budget = budget
+	}
+}
+
+// WarnOnly makes RunSuite only warn when the suite exceeds the budget.
+func WarnOnly() SuiteOption {
+	return func(c *suiteConfig) {
+		c.warnOnly = true
+	}
+}
+
+// maxSlowTests is the number of tests listed when the suite exceeds budget.
+const maxSlowTests = 10
+
+func writeBudgetReport(w io.Writer, elapsed, budget time.Duration) {
+	suite.mu.Lock()
+	durations := make(map[string]time.Duration)
+	for _, r := range suite.requests {
+		durations[r.test] += r.duration
+	}
+	suite.mu.Unlock()
+
+	tests := make([]string, 0, len(durations))
+	for name := range durations {
+		tests = append(tests, name)
+	}
+	slices.SortFunc(tests, func(a, b string) int {
+		return cmp.Or(cmp.Compare(durations[b], durations[a]), strings.Compare(a, b))
+	})
+
+	fmt.Fprintf(w, "Suite took %s, exceeding the budget of %s by %s. Slowest tests:\n", elapsed, budget, elapsed-budget)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, name := range tests[:min(len(tests), maxSlowTests)] {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, durations[name])
+	}
+	_ = tw.Flush()
+}
+
+// RouteLatency is the aggregated duration of the requests to a route.
+type RouteLatency struct {
+	Route string        `json:"route"`
+	Count int           `json:"count"`
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+// Mean returns the mean duration of the requests.
+func (l RouteLatency) Mean() time.Duration {
+	return l.Total / time.Duration(l.Count)
+}
+
+// Latencies returns the duration of the requests sent so far aggregated by
+// route pattern, slowest total first. Requests not matched by a pattern, e.g.
+// those sent to a real server, are aggregated by path.
+func Latencies() []RouteLatency {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+
+	index := make(map[string]int)
+	var ls []RouteLatency
+	for _, r := range suite.requests {
+		i, ok := index[r.route]
+		if !ok {
+			i = len(ls)
+			index[r.route] = i
+			ls = append(ls, RouteLatency{Route: r.route})
+		}
+		ls[i].Count++
+		ls[i].Total += r.duration
+		ls[i].Max = max(ls[i].Max, r.duration)
+	}
+	slices.SortStableFunc(ls, func(a, b RouteLatency) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	return ls
+}
+
+func writeLatencyReport(w io.Writer) error {
+	fmt.Fprintf(w, "Latency of run %s:\n", RunID())
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROUTE\tCOUNT\tTOTAL\tMEAN\tMAX")
+	for _, l := range Latencies() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", l.Route, l.Count, l.Total, l.Mean(), l.Max)
+	}
+	return tw.Flush()
+}
+
+func writeLatencyJSON(filename string) error {
+	report := struct {
+		RunID  string         `json:"run_id"`
+		Routes []RouteLatency `json:"routes"`
+	}{
+		RunID:  RunID(),
+		Routes: Latencies(),
+	}
+	data, err := json.MarshalIndent(&report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o600)
+}
+
+// RequestResult is the result of a request sent by a test, as written by
+// -results-json.
+type RequestResult struct {
+	RunID    string        `json:"run_id"`
+	Test     string        `json:"test"`
+	Method   string        `json:"method"`
+	Route    string        `json:"route"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+	// Handler is the source file of the handler of the route, known for
+	// requests served in-process by a ServeMux, also behind middleware, or
+	// an http.HandlerFunc.
+	Handler string `json:"handler,omitempty"`
+}
+
+// appendResults appends the results as JSON lines, so that the test binaries
+// of several packages can share the file.
+func appendResults(filename string) error {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range suite.requests {
+		if err := enc.Encode(RequestResult{RunID: RunID(), Test: r.test, Method: r.method, Route: r.route, Status: r.status, Duration: r.duration, Handler: handlerSource(r.route)}); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
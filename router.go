@@ -0,0 +1,221 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RouterFactory builds a router for RunTest. The returned release function,
+// which may be nil, is called once the router is no longer used, e.g. to
+// close the database connections of the router.
+type RouterFactory func() (h http.Handler, release func())
+
+// RouterPolicy decides how often a RouterFactory builds a router, trading
+// isolation between tests for speed.
+type RouterPolicy int
+
+const (
+	// ReuseRouter builds a single router shared by the whole suite, which is
+	// released at the end of RunSuite.
+	ReuseRouter RouterPolicy = iota
+	// RouterPerTest builds a router per top-level test, shared by its
+	// subtests. The router is released when the top-level test completes if
+	// it used the router itself, and otherwise once none of its subtests
+	// uses it and another test needs a router, or at the end of RunSuite.
+	RouterPerTest
+	// RouterPerSubtest builds a router for every test calling RunTest, shared
+	// by all its calls. The router is released when the test completes.
+	RouterPerSubtest
+)
+
+// RouterHooks are called along the lifecycle of the routers built by a
+// RouterFactory, e.g. to seed a database when a router is built and to reset
+// it before every test reusing the router. Nil hooks are not called.
+type RouterHooks struct {
+	// Build is called after a router is built for t.
+	Build func(t *testing.T, h http.Handler)
+	// Use is called when t starts using a router, whether the router was
+	// built for it or is reused.
+	Use func(t *testing.T, h http.Handler)
+	// Release is called before the release function of a router, once no
+	// test uses it anymore.
+	Release func(h http.Handler)
+}
+
+// builtRouter is a router built by the factory.
+type builtRouter struct {
+	h       http.Handler
+	release func()
+	// users is the number of running tests using the router.
+	users int
+}
+
+var factory struct {
+	mu      sync.Mutex
+	f       RouterFactory
+	policy  RouterPolicy
+	hooks   RouterHooks
+	routers map[string]*builtRouter
+	// users is the names of the running tests using a router.
+	users map[string]bool
+}
+
+// RegisterRouterFactory registers f to build routers for RunTest according
+// to policy, instead of the router registered by RegisterRouter.
+func RegisterRouterFactory(f RouterFactory, policy RouterPolicy) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+
+	factory.f = f
+	factory.policy = policy
+	factory.routers = make(map[string]*builtRouter)
+	factory.users = make(map[string]bool)
+}
+
+// RegisterRouterHooks registers the hooks called along the lifecycle of the
+// routers of the RouterFactory.
+func RegisterRouterHooks(hooks RouterHooks) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+
+	factory.hooks = hooks
+}
+
+// routerFor returns the router to serve the requests of t. The router is
+// built once per key of the policy, and used by t until t completes.
+func routerFor(t *testing.T) http.Handler {
+	t.Helper()
+
+	factory.mu.Lock()
+	if factory.f == nil {
+		factory.mu.Unlock()
+		return router
+	}
+
+	var key string
+	switch factory.policy {
+	case RouterPerTest:
+		key, _, _ = strings.Cut(t.Name(), "/")
+	case RouterPerSubtest:
+		key = t.Name()
+	}
+	hooks := factory.hooks
+	b, ok := factory.routers[key]
+	var idle []*builtRouter
+	if !ok {
+		if factory.policy == RouterPerTest {
+			idle = idleRouters()
+		}
+		b = new(builtRouter)
+		b.h, b.release = factory.f()
+		factory.routers[key] = b
+	}
+	name := t.Name()
+	using := factory.users[name]
+	if !using {
+		factory.users[name] = true
+		b.users++
+		t.Cleanup(func() { unuseRouter(name, key, b) })
+	}
+	factory.mu.Unlock()
+
+	for _, b := range idle {
+		b.close(hooks)
+	}
+	if !ok && hooks.Build != nil {
+		hooks.Build(t, b.h)
+	}
+	if !using && hooks.Use != nil {
+		hooks.Use(t, b.h)
+	}
+	return b.h
+}
+
+// unuseRouter records that the test name completed using b, the router of
+// key, releasing b when the router was built for the test.
+func unuseRouter(name, key string, b *builtRouter) {
+	factory.mu.Lock()
+	delete(factory.users, name)
+	b.users--
+	owned := factory.policy != ReuseRouter && name == key && factory.routers[key] == b
+	if owned {
+		delete(factory.routers, key)
+	}
+	hooks := factory.hooks
+	factory.mu.Unlock()
+
+	if owned {
+		b.close(hooks)
+	}
+}
+
+// idleRouters removes the routers no running test uses from the factory and
+// returns them. factory.mu must be held.
+func idleRouters() []*builtRouter {
+	var idle []*builtRouter
+	for key, b := range factory.routers {
+		if b.users == 0 {
+			idle = append(idle, b)
+			delete(factory.routers, key)
+		}
+	}
+	return idle
+}
+
+// close calls the Release hook and the release function of b.
+func (b *builtRouter) close(hooks RouterHooks) {
+	if hooks.Release != nil {
+		hooks.Release(b.h)
+	}
+	if b.release != nil {
+		b.release()
+	}
+}
+
+var namedRouters sync.Map // map[string]http.Handler
+
+// RegisterRouterNamed registers h as the router named name, to which the
+// requests built with Target are sent, so that a suite can test several
+// services such as a gateway and an internal admin service.
+func RegisterRouterNamed(name string, h http.Handler) {
+	namedRouters.Store(name, h)
+}
+
+type targetKey struct{}
+
+// Target sends the request to the router registered by RegisterRouterNamed
+// as name instead of the default one.
+func Target(name string) RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), targetKey{}, name))
+	}
+}
+
+func namedRouter(t *testing.T, name string) http.Handler {
+	t.Helper()
+
+	h, ok := namedRouters.Load(name)
+	if !ok {
+		t.Fatalf("router %q is not registered", name)
+	}
+	return h.(http.Handler)
+}
+
+// releaseRouters releases the routers still held by the factory.
+func releaseRouters() {
+	factory.mu.Lock()
+	routers := make([]*builtRouter, 0, len(factory.routers))
+	for _, b := range factory.routers {
+		routers = append(routers, b)
+	}
+	factory.routers = make(map[string]*builtRouter)
+	hooks := factory.hooks
+	factory.mu.Unlock()
+
+	for _, b := range routers {
+		b.close(hooks)
+	}
+}
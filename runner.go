@@ -0,0 +1,259 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Runner runs tests against a handler with its own configuration, so that a
+// test binary can test several services or router configurations, also from
+// parallel tests. The package-level functions use a default Runner
+// configured by RegisterRouter and the other Register functions.
+type Runner struct {
+	handler  http.Handler
+	envelope *Envelope
+	shadow   http.Handler
+	remote   *Client
+	cache    *ResponseCache
+	socket   []ServerOption
+
+	middlewares []func(http.Handler) http.Handler
+	filters     []ResponseFilter
+	families    []family
+
+	namespaceFields []string
+
+	goldenByRequest bool
+
+	profileThreshold time.Duration
+	profileDir       string
+}
+
+// RunnerOption configures a Runner.
+type RunnerOption func(*Runner)
+
+// WithEnvelope is like RegisterEnvelope for the Runner.
+func WithEnvelope(e Envelope) RunnerOption {
+	return func(rn *Runner) {
+		rn.envelope = &e
+	}
+}
+
+// WithShadow is like RegisterShadow for the Runner.
+func WithShadow(h http.Handler) RunnerOption {
+	return func(rn *Runner) {
+		rn.shadow = h
+	}
+}
+
+// OverSocket makes the Runner send every request through an httptest.Server
+// serving the handler over a real socket, so that responses go through the
+// full net/http write path. Use it for middleware behaving differently under
+// httptest.ResponseRecorder, such as IP extraction, hijacking and WebSocket
+// upgrades. The server is closed when the test completes.
+func OverSocket(opts ...ServerOption) RunnerOption {
+	return func(rn *Runner) {
+		rn.socket = append([]ServerOption{}, opts...)
+	}
+}
+
+// New returns a Runner sending requests to h.
+func New(h http.Handler, opts ...RunnerOption) *Runner {
+	rn := &Runner{handler: h}
+	for _, opt := range opts {
+		opt(rn)
+	}
+	return rn
+}
+
+// defaultRunner returns the Runner of the package-level functions.
+func defaultRunner() *Runner {
+	return &Runner{
+		envelope:         envelope,
+		shadow:           shadow,
+		remote:           remoteClient(),
+		cache:            responseCache,
+		middlewares:      middlewares,
+		filters:          registeredFilters,
+		families:         registeredFamilies,
+		namespaceFields:  namespaceFields,
+		profileThreshold: *profileSlowFlag,
+		profileDir:       *profileDirFlag,
+	}
+}
+
+// handlerFor returns the handler to serve r sent by t: the router of
+// routerOf wrapped with the middleware of rn.
+func (rn *Runner) handlerFor(t *testing.T, r *http.Request) http.Handler {
+	t.Helper()
+
+	return wrap(rn.routerOf(t, r), rn.middlewares)
+}
+
+// routerOf returns the router named by Target, or the handler of rn.
+func (rn *Runner) routerOf(t *testing.T, r *http.Request) http.Handler {
+	t.Helper()
+
+	if name, ok := r.Context().Value(targetKey{}).(string); ok {
+		return namedRouter(t, name)
+	}
+	if rn.handler != nil {
+		return rn.handler
+	}
+	return routerFor(t)
+}
+
+// RunTest is like RunTest but sends r to the handler of rn, or the live
+// server set by Remote.
+func (rn *Runner) RunTest(t *testing.T, r *http.Request, want int, filters ...ResponseFilter) {
+	t.Helper()
+
+	rn.runTest(t, r, want, filters, nil)
+}
+
+// runTest is like RunTest but calls served, if not nil, with the response as
+// soon as it is received, before it is checked or filtered.
+func (rn *Runner) runTest(t *testing.T, r *http.Request, want int, filters []ResponseFilter, served func(*http.Response)) {
+	t.Helper()
+
+	if c := rn.client(t, r); c != nil {
+		c.runTest(t, rn, r, want, filters, served)
+		return
+	}
+	runTest(t, rn, r, want, filters, served, func(r *http.Request) (*http.Response, []Interim) {
+		w := newSourceRecorder()
+		router := rn.routerOf(t, r)
+		rn.profile(t, func() { wrap(router, rn.middlewares).ServeHTTP(w, r) })
+		recordHandlerSource(router, r, w)
+		got := w.Result()
+		got.Request = r
+		return got, nil
+	})
+}
+
+// client returns the Client sending r for rn: the one of the live server set
+// by Remote, memoizing with the cache of WithResponseCache, or one of a
+// server started for OverSocket. It returns nil when requests are served in
+// process.
+func (rn *Runner) client(t *testing.T, r *http.Request) *Client {
+	t.Helper()
+
+	switch {
+	case rn.remote != nil && rn.cache != nil:
+		c := *rn.remote
+		c.cache = rn.cache
+		return &c
+	case rn.remote != nil:
+		return rn.remote
+	case rn.socket != nil:
+		return NewClient(startServer(t, rn.handlerFor(t, r), rn.socket))
+	}
+	return nil
+}
+
+// sender returns the function sending requests like r as RunTest does: to
+// the live server set by Remote, over a socket with OverSocket, or to the
+// handler wrapped with the middleware in process. The responses have their
+// bodies read and Request set to the request sent. The transport is
+// resolved once, so that the function may be called from other goroutines
+// than the test, and for many requests without starting a server for each.
+func (rn *Runner) sender(t *testing.T, r *http.Request) func(*http.Request) (*http.Response, error) {
+	t.Helper()
+
+	if c := rn.client(t, r); c != nil {
+		return func(r *http.Request) (*http.Response, error) {
+			got, err := c.Do(r)
+			if err != nil {
+				return nil, err
+			}
+			// Date changes on every request, as in RunTest.
+			got.Header.Del("Date")
+			got.Request = r
+			return got, nil
+		}
+	}
+	h := rn.handlerFor(t, r)
+	return func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		got := w.Result()
+		got.Request = r
+		return got, nil
+	}
+}
+
+// serve sends r with the sender of rn, failing t on transport errors.
+func (rn *Runner) serve(t *testing.T, r *http.Request) *http.Response {
+	t.Helper()
+
+	got, err := rn.sender(t, r)(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// readBody returns the body of r, which is kept for later reads.
+func readBody(t *testing.T, r *http.Response) []byte {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body
+}
+
+// requestMeta is the state of a test attached to the requests passed to the
+// filters through got.Request.
+type requestMeta struct {
+	runner *Runner
+	// collect makes errorf collect the failures of the filters, which RunTest
+	// reports with the mismatches of the response.
+	collect  bool
+	failures []string
+}
+
+type requestMetaKey struct{}
+
+// withRunner returns r carrying rn for the filters.
+func withRunner(r *http.Request, rn *Runner) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestMetaKey{}, &requestMeta{runner: rn}))
+}
+
+// metaOf returns the state attached to r by withRunner, or nil.
+func metaOf(r *http.Request) *requestMeta {
+	if r == nil {
+		return nil
+	}
+	m, _ := r.Context().Value(requestMetaKey{}).(*requestMeta)
+	return m
+}
+
+// runnerOf returns the Runner which sent r, or the default Runner.
+func runnerOf(r *http.Request) *Runner {
+	if m := metaOf(r); m != nil {
+		return m.runner
+	}
+	return defaultRunner()
+}
+
+// errorf reports a failed assertion of a filter on r. Within RunTest, the
+// failure is reported with the mismatches of the response, so that XFail and
+// quarantine apply to it. Otherwise it fails t.
+func errorf(t *testing.T, r *http.Response, format string, args ...any) {
+	t.Helper()
+
+	if m := metaOf(r.Request); m != nil && m.collect {
+		m.failures = append(m.failures, fmt.Sprintf(format, args...))
+		return
+	}
+	t.Errorf(format, args...)
+}
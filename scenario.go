@@ -0,0 +1,299 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Scenario is a sequence of steps sharing state, such as a business flow of
+// registration, update and deletion. A step runs after the previous step
+// unless it declares its dependencies with DependsOn, so that independent
+// branches of the flow run in parallel.
+//
+// Steps are plain functions, so a scenario can mix protocols, e.g. an HTTP
+// request followed by a gRPC call of e2egrpc, passing values between them
+// through the variables of the scenario.
+type Scenario struct {
+	steps  []*step
+	t      *testing.T
+	runner *Runner
+
+	mu   sync.Mutex
+	vars map[string]any
+	jar  http.CookieJar
+}
+
+type step struct {
+	name     string
+	fn       func(t *testing.T)
+	deps     []string
+	explicit bool
+	done     chan struct{}
+	failed   bool
+}
+
+// StepOption configures a step of a Scenario.
+type StepOption func(*step)
+
+// DependsOn makes the step run once the named steps passed, instead of after
+// the previous step. The steps must be added before the step depending on
+// them. With no names, the step starts as soon as the scenario runs.
+func DependsOn(names ...string) StepOption {
+	return func(s *step) {
+		s.deps = names
+		s.explicit = true
+	}
+}
+
+// NewScenario returns an empty Scenario.
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+// Step adds a step running fn as a subtest named name.
+func (s *Scenario) Step(name string, fn func(t *testing.T), opts ...StepOption) *Scenario {
+	st := &step{name: name, fn: fn, done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(st)
+	}
+	if !st.explicit && len(s.steps) > 0 {
+		st.deps = []string{s.steps[len(s.steps)-1].name}
+	}
+	s.steps = append(s.steps, st)
+	return s
+}
+
+// UseRunner makes Create send its requests with rn instead of the default
+// Runner.
+func (s *Scenario) UseRunner(rn *Runner) *Scenario {
+	s.runner = rn
+	return s
+}
+
+// Run runs the steps as subtests of t and waits for all of them. A step is
+// skipped when a step it depends on failed. t fails without running any step
+// when two steps have the same name, which would make dependencies and
+// subtests ambiguous.
+func (s *Scenario) Run(t *testing.T) {
+	t.Helper()
+
+	s.t = t
+	index := make(map[string]*step, len(s.steps))
+	for _, st := range s.steps {
+		if _, ok := index[st.name]; ok {
+			fatalf(t, "step %q is added more than once", st.name)
+		}
+		for _, dep := range st.deps {
+			if _, ok := index[dep]; !ok {
+				fatalf(t, "step %q depends on %q which is not added before it", st.name, dep)
+			}
+		}
+		index[st.name] = st
+	}
+
+	var wg sync.WaitGroup
+	for _, st := range s.steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(st.done)
+
+			for _, dep := range st.deps {
+				d := index[dep]
+				<-d.done
+				if d.failed {
+					st.failed = true
+					t.Run(st.name, func(t *testing.T) {
+						t.Skipf("skipped because step %q failed", dep)
+					})
+					return
+				}
+			}
+			st.failed = !t.Run(st.name, st.fn)
+		}()
+	}
+	wg.Wait()
+}
+
+type scenarioKey struct{}
+
+// NewRequest is like NewRequest but attaches the cookies of the scenario,
+// and makes RunTest store the cookies the response sets, so that a session
+// started in a step carries over to the later steps.
+func (s *Scenario) NewRequest(method, endpoint string, body io.Reader, options ...RequestOption) *http.Request {
+	r := NewRequest(method, endpoint, body, options...)
+	for _, c := range s.cookieJar().Cookies(cookieURL(r)) {
+		r.AddCookie(c)
+	}
+	return r.WithContext(context.WithValue(r.Context(), scenarioKey{}, s))
+}
+
+func (s *Scenario) cookieJar() http.CookieJar {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jar == nil {
+		// cookiejar.New only fails with invalid options.
+		s.jar, _ = cookiejar.New(nil)
+	}
+	return s.jar
+}
+
+// storeCookies stores the cookies set by got if r was built by
+// Scenario.NewRequest.
+func storeCookies(r *http.Request, got *http.Response) {
+	s, ok := r.Context().Value(scenarioKey{}).(*Scenario)
+	if !ok {
+		return
+	}
+	s.cookieJar().SetCookies(cookieURL(r), got.Cookies())
+}
+
+// cookieURL returns the URL keying the cookies of r. The scheme is https, so
+// that secure cookies are sent in process too.
+func cookieURL(r *http.Request) *url.URL {
+	host := r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	return &url.URL{Scheme: "https", Host: host, Path: r.URL.Path}
+}
+
+// Set sets the variable name shared by the steps of the scenario.
+func (s *Scenario) Set(name string, v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vars == nil {
+		s.vars = make(map[string]any)
+	}
+	s.vars[name] = v
+}
+
+// Get returns the variable name, or nil when it is not set.
+func (s *Scenario) Get(name string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.vars[name]
+}
+
+// Capture returns a ResponseFilter setting the variable name to the field at
+// path of the JSON response, or of its payload when the response is wrapped
+// in the registered envelope. path is dot-separated, with "#i" addressing the
+// ith element of an array, e.g. "users#0.id". Numbers keep their textual
+// form, so IDs can be used in later requests as they are.
+func (s *Scenario) Capture(name, path string) ResponseFilter {
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.capture(t, name, path, body, runnerOf(r.Request).envelope)
+	}
+}
+
+// CaptureJSON is like Capture for the JSON document data of another protocol,
+// e.g. a gRPC response message encoded with protojson, so that steps of any
+// protocol pass values to each other.
+func (s *Scenario) CaptureJSON(t *testing.T, name, path string, data []byte) {
+	t.Helper()
+
+	s.capture(t, name, path, data, nil)
+}
+
+func (s *Scenario) capture(t *testing.T, name, path string, data []byte, e *Envelope) {
+	t.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		fatalf(t, "Capture %s: %v", name, err)
+	}
+	got, ok := lookupPath(payload(e, v), parsePath(path))
+	if !ok {
+		fatalf(t, "Capture %s: field %q not found", name, path)
+	}
+	s.Set(name, got)
+}
+
+// TemplateBody is like TemplateBody with the variables of the scenario,
+// overridden by data, so that fixtures can be reused across chained steps.
+func (s *Scenario) TemplateBody(t *testing.T, filename string, data map[string]any) io.Reader {
+	t.Helper()
+
+	s.mu.Lock()
+	vars := maps.Clone(s.vars)
+	s.mu.Unlock()
+	if vars == nil {
+		vars = make(map[string]any)
+	}
+	maps.Copy(vars, data)
+	return TemplateBody(t, filename, vars)
+}
+
+// Expand replaces the {name} placeholders in endpoint with the variables of
+// the scenario, e.g. "/v1/user/{id}". t fails when a variable is not set.
+func (s *Scenario) Expand(t *testing.T, endpoint string) string {
+	t.Helper()
+
+	var b strings.Builder
+	for rest := endpoint; ; {
+		before, after, found := strings.Cut(rest, "{")
+		b.WriteString(before)
+		if !found {
+			return b.String()
+		}
+		name, after, found := strings.Cut(after, "}")
+		if !found {
+			fatalf(t, "unterminated placeholder in %q", endpoint)
+		}
+		v := s.Get(name)
+		if v == nil {
+			fatalf(t, "scenario variable %q of %q is not set", name, endpoint)
+		}
+		fmt.Fprint(&b, v)
+		rest = after
+	}
+}
+
+// parsePath parses a path formatted by formatPath.
+func parsePath(s string) []any {
+	var path []any
+	for _, field := range strings.Split(s, ".") {
+		name, indexes, _ := strings.Cut(field, "#")
+		if name != "" {
+			path = append(path, name)
+		}
+		if indexes == "" {
+			continue
+		}
+		for _, index := range strings.Split(indexes, "#") {
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				panic(fmt.Sprintf("e2e: invalid index %q in path %q", index, s))
+			}
+			path = append(path, i)
+		}
+	}
+	return path
+}
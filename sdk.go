@@ -0,0 +1,116 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"testing"
+	"time"
+)
+
+// RunSDK runs call with an *http.Client whose requests are served by the
+// registered router, as RunTest serves them, so that a generated client SDK can be tested
+// against the server in one place. The wire traffic, with filters applied
+// to the responses, is compared with the golden file, and the value and
+// error returned by call with the .sdk.golden file next to it.
+//
+// The filters only apply to the golden file; the SDK decodes the responses
+// as they are.
+func RunSDK[T any](t *testing.T, call func(c *http.Client) (T, error), filters ...ResponseFilter) {
+	t.Helper()
+
+	RunSDKWith(t, defaultRunner(), call, filters...)
+}
+
+// RunSDKWith is like RunSDK but serves the requests with rn, since methods
+// cannot have type parameters.
+func RunSDKWith[T any](t *testing.T, rn *Runner, call func(c *http.Client) (T, error), filters ...ResponseFilter) {
+	t.Helper()
+
+	rt := &sdkTransport{t: t, runner: rn, filters: filters}
+	v, err := call(&http.Client{Transport: rt})
+
+	result := struct {
+		Value T      `json:"value"`
+		Error string `json:"error,omitempty"`
+	}{Value: v}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	decoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failures []string
+	if mismatch := compareGolden(t, rt.wire.Bytes()); mismatch != "" {
+		failures = append(failures, "SDK traffic "+mismatch)
+	}
+	compareSidecar(t, ".sdk.golden", decoded)
+	t.Logf("<<< %s\n", goldenFileName(t.Name()))
+	reportFailures(t, failures)
+}
+
+// sdkTransport serves requests with the Runner, recording the traffic.
+type sdkTransport struct {
+	t       *testing.T
+	runner  *Runner
+	send    func(*http.Request) (*http.Response, error)
+	filters []ResponseFilter
+	wire    bytes.Buffer
+}
+
+func (rt *sdkTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t := rt.t
+	t.Helper()
+	t.Logf(">>> %s %s\n", r.Method, r.URL)
+
+	dump, err := httputil.DumpRequest(r, true)
+	if err != nil {
+		return nil, err
+	}
+	rt.wire.Write(dump)
+	rt.wire.WriteString("\r\n")
+
+	in := r.Clone(r.Context())
+	in.RequestURI = r.URL.RequestURI()
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	if rt.send == nil {
+		rt.send = rt.runner.sender(t, in)
+	}
+	start := time.Now()
+	got, err := rt.send(in)
+	if err != nil {
+		return nil, err
+	}
+	got.Request = r
+	recordRequest(t, in, got.StatusCode, time.Since(start))
+
+	body, err := io.ReadAll(got.Body)
+	if err != nil {
+		return nil, err
+	}
+	got.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Filters apply to a copy, leaving the response to the SDK intact.
+	filtered := *got
+	filtered.Header = got.Header.Clone()
+	filtered.Body = io.NopCloser(bytes.NewReader(body))
+	normalizeEnvelope(t, rt.runner.envelope, &filtered)
+	for _, f := range rt.filters {
+		f(t, &filtered)
+	}
+	normalizeContentLength(r, &filtered)
+	dump, err = httputil.DumpResponse(&filtered, true)
+	if err != nil {
+		return nil, err
+	}
+	rt.wire.Write(dump)
+	rt.wire.WriteString("\r\n")
+	return got, nil
+}
@@ -0,0 +1,75 @@
+package e2e
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ServerOption configures the server started by StartServer.
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	configure   []func(*http.Server)
+	middlewares []func(http.Handler) http.Handler
+	http2       bool
+	h2c         bool
+}
+
+// WithServerConfig calls f with the underlying http.Server before it starts,
+// so that timeouts and other settings match the production server.
+func WithServerConfig(f func(*http.Server)) ServerOption {
+	return func(c *serverConfig) {
+		c.configure = append(c.configure, f)
+	}
+}
+
+// WithHTTP2 makes the server serve HTTP/2 over TLS. Clients created by
+// NewClient negotiate HTTP/2 with it.
+func WithHTTP2() ServerOption {
+	return func(c *serverConfig) {
+		c.http2 = true
+	}
+}
+
+// WithH2C makes the server accept HTTP/2 without TLS (h2c) in addition to
+// HTTP/1.1. Clients created by NewClient speak h2c to it.
+func WithH2C() ServerOption {
+	return func(c *serverConfig) {
+		c.h2c = true
+	}
+}
+
+// StartServer starts an httptest.Server serving the registered router over a
+// real socket. The server is closed when t and all its subtests complete.
+func StartServer(t *testing.T, opts ...ServerOption) *httptest.Server {
+	t.Helper()
+
+	return startServer(t, wrap(routerFor(t), middlewares), opts)
+}
+
+func startServer(t *testing.T, h http.Handler, opts []ServerOption) *httptest.Server {
+	t.Helper()
+
+	var cfg serverConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	srv := httptest.NewUnstartedServer(wrap(h, cfg.middlewares))
+	for _, f := range cfg.configure {
+		f(srv.Config)
+	}
+	if cfg.h2c {
+		srv.Config.Protocols = new(http.Protocols)
+		srv.Config.Protocols.SetHTTP1(true)
+		srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if cfg.http2 {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,112 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var shadow http.Handler
+
+// RegisterShadow makes RunTest send each request to h as well, and fail
+// with the semantic differences between the responses, to support rewrites
+// and framework migrations. The filters are applied to both responses
+// before comparison. To shadow a remote target such as staging, register
+// an httputil.ReverseProxy. Register nil to stop shadowing.
+func RegisterShadow(h http.Handler) {
+	shadow = h
+}
+
+// shadowRequest returns a copy of r for the shadow, leaving r readable.
+func shadowRequest(t *testing.T, r *http.Request) *http.Request {
+	t.Helper()
+
+	sr := r.Clone(r.Context())
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sr.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if sr.RequestURI == "" {
+		sr.RequestURI = sr.URL.RequestURI()
+	}
+	return sr
+}
+
+// serveShadow serves sr with the shadow of rn and applies the filters.
+func serveShadow(t *testing.T, rn *Runner, sr *http.Request, filters []ResponseFilter) *http.Response {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	rn.shadow.ServeHTTP(w, sr)
+	got := w.Result()
+	got.Request = withRunner(sr, rn)
+	decodeContentEncoding(t, got)
+	normalizeEnvelope(t, rn.envelope, got)
+	for _, f := range filters {
+		f(t, got)
+	}
+	return got
+}
+
+// responseDiff returns the semantic differences of the response named name
+// from got: the status code, the header fields except Date and
+// Content-Length and the fields of JSON bodies, or the whole body otherwise.
+func responseDiff(t *testing.T, got, other *http.Response, name string) []string {
+	t.Helper()
+
+	var diffs []string
+	if got.StatusCode != other.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status code %d, %s %d", got.StatusCode, name, other.StatusCode))
+	}
+
+	header, otherHeader := got.Header.Clone(), other.Header.Clone()
+	for _, h := range []http.Header{header, otherHeader} {
+		h.Del("Date")
+		h.Del("Content-Length")
+	}
+	added, removed, changed := diffKeys(flattenHeader(header), flattenHeader(otherHeader))
+	diffs = appendDiffs(diffs, "header", name, added, removed, changed)
+
+	var rc, orc io.ReadCloser
+	rc, got.Body = drainBody(t, got.Body)
+	orc, other.Body = drainBody(t, other.Body)
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherBody, err := io.ReadAll(orc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v, ov any
+	if json.Unmarshal(body, &v) == nil && json.Unmarshal(otherBody, &ov) == nil {
+		added, removed, changed := diffKeys(flattenJSON(v, ""), flattenJSON(ov, ""))
+		diffs = appendDiffs(diffs, "field", name, added, removed, changed)
+	} else if !slices.Equal(body, otherBody) {
+		diffs = append(diffs, "body differs in "+name)
+	}
+	return diffs
+}
+
+func appendDiffs(diffs []string, kind, name string, added, removed, changed []string) []string {
+	if len(added) > 0 {
+		diffs = append(diffs, fmt.Sprintf("%ss only in %s: %s", kind, name, strings.Join(added, ", ")))
+	}
+	if len(removed) > 0 {
+		diffs = append(diffs, fmt.Sprintf("%ss missing in %s: %s", kind, name, strings.Join(removed, ", ")))
+	}
+	if len(changed) > 0 {
+		diffs = append(diffs, fmt.Sprintf("%ss changed in %s: %s", kind, name, strings.Join(changed, ", ")))
+	}
+	return diffs
+}
@@ -0,0 +1,65 @@
+package e2e
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var shuffleSeedFlag = flag.Uint64("shuffle-seed", 0, "seed of the order of Shuffle to replay; 0 picks a random seed reported at suite end")
+
+var shuffleSeed struct {
+	once sync.Once
+	seed uint64
+	used bool
+}
+
+// seedOfShuffle returns the seed of the suite, reporting that it is used.
+func seedOfShuffle() uint64 {
+	shuffleSeed.once.Do(func() {
+		shuffleSeed.seed = *shuffleSeedFlag
+		for shuffleSeed.seed == 0 {
+			shuffleSeed.seed = rand.Uint64()
+		}
+		shuffleSeed.used = true
+	})
+	return shuffleSeed.seed
+}
+
+// Shuffle returns a copy of the cases of a table-driven test in random order,
+// flushing out hidden dependencies between cases which a fixed order masks:
+//
+//	for _, tt := range e2e.Shuffle(t, tests) {
+//
+// The order depends only on the seed of the suite and the name of t, so
+// that -shuffle-seed replays it also for a subset of the tests. The seed is
+// logged by t and reported at suite end. Use go test -shuffle=on to shuffle
+// top-level tests.
+func Shuffle[T any](t *testing.T, cases []T) []T {
+	t.Helper()
+
+	seed := seedOfShuffle()
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, t.Name())
+	r := rand.New(rand.NewPCG(seed, h.Sum64()))
+
+	shuffled := slices.Clone(cases)
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	t.Logf("cases shuffled with -shuffle-seed=%d\n", seed)
+	return shuffled
+}
+
+// writeShuffleReport writes the seed of Shuffle if it was used.
+func writeShuffleReport(w io.Writer) {
+	if !shuffleSeed.used {
+		return
+	}
+	fmt.Fprintf(w, "e2e: cases shuffled with seed %d; replay with -shuffle-seed=%d\n", shuffleSeed.seed, shuffleSeed.seed)
+}
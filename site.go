@@ -0,0 +1,199 @@
+package e2e
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ExportSite renders the golden files under dir indexed by -golden into a
+// static site under out, with a page per endpoint listing its responses by
+// status and syntax-highlighted bodies, so that the behavior of the API can
+// be browsed without reading the tests.
+func ExportSite(dir, out string) error {
+	entries, err := readMockIndex(filepath.Join(dir, mockIndexName))
+	if err != nil {
+		return err
+	}
+
+	endpoints := make(map[string]*siteEndpoint)
+	for _, golden := range slices.Sorted(maps.Keys(entries)) {
+		data, err := os.ReadFile(filepath.Join(dir, golden))
+		if err != nil {
+			return err
+		}
+		if data, _, err = decodeGolden(data); err != nil {
+			return fmt.Errorf("%s: %w", golden, err)
+		}
+		code, header, body, ok := parseDump(data)
+		if !ok {
+			return fmt.Errorf("%s: malformed golden file", golden)
+		}
+		request, query, _ := strings.Cut(entries[golden], "?")
+		e, ok := endpoints[request]
+		if !ok {
+			e = &siteEndpoint{Request: request, Page: sitePageName(request)}
+			endpoints[request] = e
+		}
+		e.Responses = append(e.Responses, siteResponse{
+			Golden: strings.TrimSuffix(golden, ".golden"),
+			Query:  query,
+			Status: code,
+			Text:   http.StatusText(code),
+			Header: header,
+			Body:   highlight(header.Get("Content-Type"), body),
+		})
+	}
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+	index := slices.SortedFunc(maps.Values(endpoints), func(a, b *siteEndpoint) int {
+		return cmp.Compare(a.Request, b.Request)
+	})
+	for _, e := range index {
+		slices.SortStableFunc(e.Responses, func(a, b siteResponse) int {
+			return cmp.Compare(a.Status, b.Status)
+		})
+		if err := writeSitePage(filepath.Join(out, e.Page), endpointTemplate, e); err != nil {
+			return err
+		}
+	}
+	return writeSitePage(filepath.Join(out, "index.html"), indexTemplate, index)
+}
+
+type siteEndpoint struct {
+	Request   string
+	Page      string
+	Responses []siteResponse
+}
+
+type siteResponse struct {
+	Golden string
+	Query  string
+	Status int
+	Text   string
+	Header http.Header
+	Body   template.HTML
+}
+
+// Statuses returns the distinct statuses of the responses in order.
+func (e *siteEndpoint) Statuses() []int {
+	var statuses []int
+	for _, r := range e.Responses {
+		if !slices.Contains(statuses, r.Status) {
+			statuses = append(statuses, r.Status)
+		}
+	}
+	return statuses
+}
+
+var unsafePageChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sitePageName returns the file name of the page of the endpoint request,
+// e.g. "GET_v1_user_1.html" for "GET /v1/user/1".
+func sitePageName(request string) string {
+	return strings.Trim(unsafePageChars.ReplaceAllString(request, "_"), "_") + ".html"
+}
+
+func writeSitePage(filename string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
+
+var (
+	jsonTokens = regexp.MustCompile(`"(?:[^"\\]|\\.)*"(\s*:)?|-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?|\b(?:true|false|null)\b`)
+	xmlTokens  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// highlight returns body as HTML with the tokens of JSON and XML bodies
+// wrapped in spans classed by kind.
+func highlight(contentType string, body []byte) template.HTML {
+	var tokens *regexp.Regexp
+	switch {
+	case json.Valid(body):
+		tokens = jsonTokens
+	case strings.Contains(contentType, "xml") || strings.Contains(contentType, "html"):
+		tokens = xmlTokens
+	default:
+		return template.HTML(template.HTMLEscapeString(string(body)))
+	}
+
+	var buf strings.Builder
+	last := 0
+	for _, m := range tokens.FindAllSubmatchIndex(body, -1) {
+		buf.WriteString(template.HTMLEscapeString(string(body[last:m[0]])))
+		token := body[m[0]:m[1]]
+		class := "tag"
+		if tokens == jsonTokens {
+			switch {
+			case len(m) > 2 && m[2] >= 0:
+				class = "key"
+			case token[0] == '"':
+				class = "string"
+			case token[0] == 't' || token[0] == 'f' || token[0] == 'n':
+				class = "literal"
+			default:
+				class = "number"
+			}
+		}
+		fmt.Fprintf(&buf, `<span class="%s">%s</span>`, class, template.HTMLEscapeString(string(token)))
+		last = m[1]
+	}
+	buf.WriteString(template.HTMLEscapeString(string(body[last:])))
+	return template.HTML(buf.String())
+}
+
+var siteFuncs = template.FuncMap{
+	// class returns the class of the status code, e.g. 2 for 2xx.
+	"class": func(code int) int { return code / 100 },
+}
+
+const siteStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+.status-2 { color: #1a7f37; } .status-3 { color: #0969da; } .status-4 { color: #9a6700; } .status-5 { color: #cf222e; }
+.key { color: #0550ae; } .string { color: #0a3069; } .number { color: #953800; } .literal { color: #8250df; } .tag { color: #116329; }
+</style>`
+
+var indexTemplate = template.Must(template.New("index").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>API responses</title>` + siteStyle + `</head>
+<body>
+<h1>API responses</h1>
+<table>
+<tr><th>Endpoint</th><th>Statuses</th></tr>
+{{- range .}}
+<tr><td><a href="{{.Page}}">{{.Request}}</a></td><td>{{range .Statuses}}<span class="status-{{class .}}">{{.}}</span> {{end}}</td></tr>
+{{- end}}
+</table>
+</body></html>
+`))
+
+var endpointTemplate = template.Must(template.New("endpoint").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Request}}</title>` + siteStyle + `</head>
+<body>
+<p><a href="index.html">All endpoints</a></p>
+<h1>{{.Request}}</h1>
+{{- range .Responses}}
+<h2 class="status-{{class .Status}}">{{.Status}} {{.Text}}</h2>
+<p>{{.Golden}}{{if .Query}} with <code>?{{.Query}}</code>{{end}}</p>
+<pre>{{range $k, $v := .Header}}{{range $v}}{{$k}}: {{.}}
+{{end}}{{end}}</pre>
+{{- if .Body}}
+<pre>{{.Body}}</pre>
+{{- end}}
+{{- end}}
+</body></html>
+`))
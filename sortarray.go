@@ -0,0 +1,109 @@
+package e2e
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// SortOption configures SortArray.
+type SortOption func(*sortConfig)
+
+type sortConfig struct {
+	key string
+}
+
+// ByKey makes SortArray sort the objects of the array by the field at key,
+// in the syntax of Capture, instead of by their whole JSON encoding.
+func ByKey(key string) SortOption {
+	return func(c *sortConfig) {
+		c.key = key
+	}
+}
+
+// SortArray returns a ResponseFilter sorting the array at path of the JSON
+// response body, in the syntax of Capture, e.g. "items", or the body itself
+// when path is empty, so that lists ordered by map iteration or query plans
+// do not make golden files flaky. Numbers sort numerically, strings
+// lexically, and other values by their JSON encoding. The path is relative
+// to the payload of the registered Envelope. With -strict, paths that do not
+// exist fail the test.
+func SortArray(path string, opts ...SortOption) ResponseFilter {
+	var cfg sortConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var key []any
+	if cfg.key != "" {
+		key = parsePath(cfg.key)
+	}
+
+	return func(t *testing.T, r *http.Response) {
+		t.Helper()
+
+		if bodyless(r.StatusCode) {
+			return
+		}
+		var tmp any
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+
+		root := payload(runnerOf(r.Request).envelope, tmp)
+		v, ok := root, true
+		if path != "" {
+			v, ok = lookupPath(root, parsePath(path))
+		}
+		arr, isArray := v.([]any)
+		switch {
+		case !ok:
+			if *strictMode {
+				errorf(t, r, "SortArray: path %q matched nothing\n", path)
+			}
+		case !isArray:
+			t.Fatalf("SortArray: %q is not an array\n", path)
+		default:
+			slices.SortStableFunc(arr, func(a, b any) int {
+				if key != nil {
+					a, _ = lookupPath(a, key)
+					b, _ = lookupPath(b, key)
+				}
+				return compareJSON(a, b)
+			})
+		}
+
+		body := new(bytes.Buffer)
+		if err := json.NewEncoder(body).Encode(&tmp); err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(body)
+	}
+}
+
+// compareJSON orders the decoded JSON values a and b.
+func compareJSON(a, b any) int {
+	switch a := a.(type) {
+	case json.Number:
+		if b, ok := b.(json.Number); ok {
+			x, errA := a.Float64()
+			y, errB := b.Float64()
+			if errA == nil && errB == nil {
+				return cmp.Compare(x, y)
+			}
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return cmp.Compare(a, b)
+		}
+	}
+	// Values decoded from JSON always encode.
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Compare(x, y)
+}
@@ -0,0 +1,276 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SSEOption configures RunSSE.
+type SSEOption func(*sseConfig)
+
+type sseConfig struct {
+	count    int
+	timeout  time.Duration
+	sentinel func(Event) bool
+}
+
+// MaxEvents makes RunSSE stop after n events.
+func MaxEvents(n int) SSEOption {
+	return func(c *sseConfig) {
+		c.count = n
+	}
+}
+
+// EventTimeout makes RunSSE stop when no event arrives within d. The default
+// is 5 seconds.
+func EventTimeout(d time.Duration) SSEOption {
+	return func(c *sseConfig) {
+		c.timeout = d
+	}
+}
+
+// UntilEvent makes RunSSE stop after the first event for which f returns
+// true.
+func UntilEvent(f func(Event) bool) SSEOption {
+	return func(c *sseConfig) {
+		c.sentinel = f
+	}
+}
+
+// Event is a server-sent event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry string
+}
+
+// RunSSE sends r to the router and reads the server-sent events it streams
+// until the handler returns, MaxEvents are read, the UntilEvent sentinel
+// arrives or no event arrives within EventTimeout, then cancels the request.
+// It checks the status code and compares the headers and the events with
+// the golden file, or updates it with -golden. Event IDs are replaced with
+// their sequence numbers, since they usually change on every run.
+func RunSSE(t *testing.T, r *http.Request, want int, opts ...SSEOption) {
+	t.Helper()
+
+	defaultRunner().RunSSE(t, r, want, opts...)
+}
+
+// RunSSE is like RunSSE but sends r with rn.
+func (rn *Runner) RunSSE(t *testing.T, r *http.Request, want int, opts ...SSEOption) {
+	t.Helper()
+
+	cfg := sseConfig{timeout: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t.Logf(">>> %s %s\n", r.Method, r.URL)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+	got, stop := rn.stream(t, r, cfg.timeout)
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		readEvents(ctx, got.Body, events)
+	}()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", got.StatusCode, http.StatusText(got.StatusCode))
+	if err := got.Header.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("\r\n")
+
+	var n int
+	timer := time.NewTimer(cfg.timeout)
+	defer timer.Stop()
+read:
+	for cfg.count == 0 || n < cfg.count {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				break read
+			}
+			n++
+			writeEvent(&buf, n, e)
+			if cfg.sentinel != nil && cfg.sentinel(e) {
+				break read
+			}
+			timer.Reset(cfg.timeout)
+		case <-timer.C:
+			t.Logf("no event within %s; stopped after %d events\n", cfg.timeout, n)
+			break read
+		}
+	}
+	cancel()
+	stop()
+
+	var failures []string
+	if got.StatusCode != want {
+		failures = append(failures, fmt.Sprintf("HTTP StatusCode: %d, want: %d\n", got.StatusCode, want))
+	}
+	if mismatch := compareGolden(t, buf.Bytes()); mismatch != "" {
+		failures = append(failures, "Events "+mismatch)
+	}
+	t.Logf("<<< %s\n", goldenFileName(t.Name()))
+	reportFailures(t, failures)
+}
+
+// stream sends r as the sender of rn does, but returns as soon as the
+// response headers are written, with the body read as the handler writes
+// it. It fails t when no response starts within timeout. The context of r
+// must be canceled before calling stop, which closes the body and waits for
+// the handler to return.
+func (rn *Runner) stream(t *testing.T, r *http.Request, timeout time.Duration) (*http.Response, func()) {
+	t.Helper()
+
+	if c := rn.client(t, r); c != nil {
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		opened := make(chan result, 1)
+		go func() {
+			resp, err := c.open(r)
+			opened <- result{resp, err}
+		}()
+		select {
+		case res := <-opened:
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			return res.resp, func() { _ = res.resp.Body.Close() }
+		case <-time.After(timeout):
+			t.Fatalf("no response within %s", timeout)
+		}
+	}
+
+	pr, pw := io.Pipe()
+	w := &sseWriter{header: make(http.Header), pw: pw, started: make(chan struct{})}
+	h := rn.handlerFor(t, r)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer pw.Close()
+		h.ServeHTTP(w, r)
+		w.WriteHeader(http.StatusOK)
+	}()
+
+	select {
+	case <-w.started:
+	case <-time.After(timeout):
+		t.Fatalf("no response within %s", timeout)
+	}
+	got := &http.Response{
+		Status:     fmt.Sprintf("%d %s", w.code, http.StatusText(w.code)),
+		StatusCode: w.code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.header,
+		Body:       pr,
+		Request:    r,
+	}
+	return got, func() {
+		_ = pr.CloseWithError(context.Canceled)
+		<-done
+	}
+}
+
+// sseWriter streams the response body through a pipe, since
+// httptest.ResponseRecorder buffers it until the handler returns.
+type sseWriter struct {
+	header  http.Header
+	code    int
+	pw      *io.PipeWriter
+	started chan struct{}
+}
+
+func (w *sseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *sseWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	close(w.started)
+}
+
+func (w *sseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(p)
+}
+
+func (w *sseWriter) Flush() {}
+
+// readEvents parses the event stream read from r until ctx is done.
+func readEvents(ctx context.Context, r io.Reader, events chan<- Event) {
+	var e Event
+	var data []string
+	var pending bool
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			if pending {
+				e.Data = strings.Join(data, "\n")
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			e, data, pending = Event{}, nil, false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		pending = true
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			e.Retry = value
+		}
+	}
+}
+
+// writeEvent writes e in the event stream format with its ID replaced by
+// the sequence number n.
+func writeEvent(buf *bytes.Buffer, n int, e Event) {
+	if e.Event != "" {
+		fmt.Fprintf(buf, "event: %s\n", e.Event)
+	}
+	if e.ID != "" {
+		fmt.Fprintf(buf, "id: %s\n", strconv.Itoa(n))
+	}
+	if e.Retry != "" {
+		fmt.Fprintf(buf, "retry: %s\n", e.Retry)
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+}
@@ -0,0 +1,59 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type allowBodyKey struct{}
+
+// AllowBody allows a body on GET and HEAD requests, which RunTest otherwise
+// rejects as a likely mistake, since servers commonly ignore it.
+func AllowBody() RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), allowBodyKey{}, true))
+	}
+}
+
+// validateEndpoint panics with an actionable message when endpoint cannot
+// be sent as is, instead of the confusing one of httptest.NewRequest.
+func validateEndpoint(endpoint string) {
+	path, _, _ := strings.Cut(endpoint, "?")
+	if strings.ContainsAny(path, " \t\r\n") {
+		panic(fmt.Sprintf("e2e: endpoint %q contains whitespace; escape path segments with url.PathEscape", endpoint))
+	}
+	if strings.ContainsAny(endpoint, " \t\r\n") {
+		panic(fmt.Sprintf("e2e: endpoint %q contains whitespace; pass query parameters with WithQuery", endpoint))
+	}
+}
+
+// validateRequest returns the common mistakes in building r, each with how
+// to fix it, before they cause confusing handler behavior. The body of r is
+// left readable.
+func validateRequest(r *http.Request) ([]string, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	var mistakes []string
+	if allowed, _ := r.Context().Value(allowBodyKey{}).(bool); !allowed && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		mistakes = append(mistakes, fmt.Sprintf("%s request has a body, which servers commonly ignore; pass nil, or AllowBody() if it is intended", r.Method))
+	}
+	if trimmed := bytes.TrimSpace(body); r.Header.Get("Content-Type") == "" && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		mistakes = append(mistakes, `JSON body without Content-Type; build it with JSONBody, or add WithContentType("application/json")`)
+	}
+	return mistakes, nil
+}
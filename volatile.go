@@ -0,0 +1,92 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// stableDate replaces the HTTP dates of volatile headers, the same time
+// examples use for created_time.
+var stableDate = time.Unix(1677136520, 0).UTC().Format(http.TimeFormat)
+
+// volatileHeaders are the headers whose values identify the request, which
+// are replaced with "***" in golden files.
+var volatileHeaders = []string{
+	"Request-Id",
+	"Traceparent",
+	"Tracestate",
+	"X-Amzn-Trace-Id",
+	"X-Cloud-Trace-Context",
+	"X-Correlation-Id",
+	"X-Request-Id",
+	"X-Trace-Id",
+}
+
+// RegisterVolatileHeader adds the header keys, e.g. a request ID header
+// specific to the service, to the headers whose values are replaced with
+// "***" in golden files.
+func RegisterVolatileHeader(keys ...string) {
+	for _, k := range keys {
+		volatileHeaders = append(volatileHeaders, http.CanonicalHeaderKey(k))
+	}
+}
+
+type keepVolatileKey struct{}
+
+// KeepVolatileHeaders keeps the values of the header keys, or of all
+// headers when none are given, which RunTest otherwise normalizes, e.g. for
+// tests asserting the request ID is propagated.
+func KeepVolatileHeaders(keys ...string) RequestOption {
+	keys = slices.Clone(keys)
+	for i, k := range keys {
+		keys[i] = http.CanonicalHeaderKey(k)
+	}
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), keepVolatileKey{}, keys))
+	}
+}
+
+var cookieExpires = regexp.MustCompile(`(?i)(;\s*expires=)[^;]*`)
+
+// normalizeVolatileHeaders replaces the values of h changing on every
+// request with stable ones before the dump, so that golden files do not
+// churn: Date and Expires with a fixed date, the Expires attributes of
+// Set-Cookie likewise, and the values of volatileHeaders with "***", except
+// the headers kept by KeepVolatileHeaders for r.
+func normalizeVolatileHeaders(r *http.Request, h http.Header) {
+	var kept []string
+	if r != nil {
+		keys, ok := r.Context().Value(keepVolatileKey{}).([]string)
+		if ok && len(keys) == 0 {
+			return
+		}
+		kept = keys
+	}
+	normalize := func(key string, f func(v string) string) {
+		if slices.Contains(kept, key) {
+			return
+		}
+		for i, v := range h[key] {
+			h[key][i] = f(v)
+		}
+	}
+
+	httpDate := func(v string) string {
+		if _, err := http.ParseTime(v); err != nil {
+			// E.g. "Expires: 0" is not a date and stable already.
+			return v
+		}
+		return stableDate
+	}
+	normalize("Date", httpDate)
+	normalize("Expires", httpDate)
+	normalize("Set-Cookie", func(v string) string {
+		return cookieExpires.ReplaceAllString(v, "${1}"+stableDate)
+	})
+	for _, k := range volatileHeaders {
+		normalize(k, func(string) string { return "***" })
+	}
+}
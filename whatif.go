@@ -0,0 +1,151 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// Mutation is a change of a single field of a JSON request body.
+type Mutation struct {
+	name   string
+	path   string
+	value  any
+	delete bool
+}
+
+// SetField returns the Mutation setting the field at path, in the syntax of
+// Capture, to value.
+func SetField(path string, value any) Mutation {
+	v, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("e2e: SetField(%q): %v", path, err))
+	}
+	return Mutation{name: fmt.Sprintf("set %s=%s", path, v), path: path, value: value}
+}
+
+// DeleteField returns the Mutation deleting the field at path.
+func DeleteField(path string) Mutation {
+	return Mutation{name: "delete " + path, path: path, delete: true}
+}
+
+// WhatIf sends the request with the JSON body base and a variant of it per
+// mutation to the router, and compares a matrix of how the response to each
+// variant differs from the response to base with the golden file, so that
+// validation behavior can be explored and then pinned. The filters are
+// applied to every response, so that normalized fields are not reported.
+func WhatIf(t *testing.T, method, endpoint string, base map[string]any, mutations []Mutation, filters ...ResponseFilter) {
+	t.Helper()
+
+	defaultRunner().WhatIf(t, method, endpoint, base, mutations, filters...)
+}
+
+// WhatIf is like WhatIf but sends the requests with rn.
+func (rn *Runner) WhatIf(t *testing.T, method, endpoint string, base map[string]any, mutations []Mutation, filters ...ResponseFilter) {
+	t.Helper()
+
+	var sender func(*http.Request) (*http.Response, error)
+	send := func(body map[string]any) *http.Response {
+		t.Helper()
+
+		r := NewRequest(method, endpoint, JSONBody(t, body))
+		if sender == nil {
+			sender = rn.sender(t, r)
+		}
+		got, err := sender(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Request = withRunner(r, rn)
+		decodeContentEncoding(t, got)
+		normalizeEnvelope(t, rn.envelope, got)
+		for _, f := range filters {
+			f(t, got)
+		}
+		return got
+	}
+
+	want := send(base)
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MUTATION\tSTATUS\tCHANGES")
+	fmt.Fprintf(tw, "(base)\t%d\t\n", want.StatusCode)
+	for _, m := range mutations {
+		body := cloneJSON(t, base)
+		if err := mutate(body, parsePath(m.path), m); err != nil {
+			t.Fatalf("WhatIf: %s: %v\n", m.name, err)
+		}
+		got := send(body)
+		diffs := responseDiff(t, want, got, "variant")
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", m.name, got.StatusCode, strings.Join(diffs, "; "))
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// Trim the padding tabwriter leaves before empty changes.
+	matrix := regexp.MustCompile(` +\n`).ReplaceAll(buf.Bytes(), []byte("\n"))
+	t.Logf("what-if %s %s:\n%s", method, endpoint, matrix)
+	CompareGolden(t, matrix)
+}
+
+// cloneJSON returns a deep copy of m as decoded from JSON.
+func cloneJSON(t *testing.T, m map[string]any) map[string]any {
+	t.Helper()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clone map[string]any
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&clone); err != nil {
+		t.Fatal(err)
+	}
+	return clone
+}
+
+// mutate applies m to the field of v at path.
+func mutate(v any, path []any, m Mutation) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty path")
+	}
+	last := len(path) == 1
+	switch key := path[0].(type) {
+	case string:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q is not in an object", key)
+		}
+		if !last {
+			child, ok := obj[key]
+			if !ok {
+				return fmt.Errorf("no field %q", key)
+			}
+			return mutate(child, path[1:], m)
+		}
+		if m.delete {
+			delete(obj, key)
+		} else {
+			obj[key] = m.value
+		}
+	case int:
+		arr, ok := v.([]any)
+		if !ok || key < 0 || key >= len(arr) {
+			return fmt.Errorf("no element %d", key)
+		}
+		if !last {
+			return mutate(arr[key], path[1:], m)
+		}
+		if m.delete {
+			return fmt.Errorf("cannot delete element %d", key)
+		}
+		arr[key] = m.value
+	}
+	return nil
+}
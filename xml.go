@@ -0,0 +1,146 @@
+package e2e
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// XMLBody encodes v with the XML declaration and returns it as a Body of
+// application/xml.
+func XMLBody(t *testing.T, v any) io.Reader {
+	t.Helper()
+
+	body := bytes.NewBufferString(xml.Header)
+	if err := xml.NewEncoder(body).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return typedBody{Buffer: body, contentType: "application/xml"}
+}
+
+// PrettyXML is a ResponseFilter indenting XML bodies with attributes sorted
+// by name, so that golden files are readable and stable across serializers
+// ordering attributes differently.
+func PrettyXML(t *testing.T, r *http.Response) {
+	t.Helper()
+
+	if bodyless(r.StatusCode) {
+		return
+	}
+	if !strings.Contains(r.Header.Get("Content-Type"), "xml") {
+		t.Fatal("Response is not XML")
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(indentXML(t, body)))
+}
+
+func indentXML(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	// Raw tokens keep the namespace prefixes as written.
+	var tokens []xml.Token
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(c)) == 0 {
+			continue
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+
+	var buf bytes.Buffer
+	depth := 0
+	indent := func() {
+		buf.WriteString(strings.Repeat("  ", depth))
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch tok := tokens[i].(type) {
+		case xml.ProcInst:
+			indent()
+			buf.WriteString("<?" + tok.Target)
+			if len(tok.Inst) > 0 {
+				buf.WriteString(" ")
+				buf.Write(tok.Inst)
+			}
+			buf.WriteString("?>\n")
+		case xml.Directive:
+			indent()
+			buf.WriteString("<!")
+			buf.Write(tok)
+			buf.WriteString(">\n")
+		case xml.Comment:
+			indent()
+			buf.WriteString("<!--")
+			buf.Write(tok)
+			buf.WriteString("-->\n")
+		case xml.StartElement:
+			indent()
+			writeStartElement(&buf, tok)
+			if i+1 < len(tokens) {
+				if _, ok := tokens[i+1].(xml.EndElement); ok {
+					buf.WriteString("/>\n")
+					i++
+					continue
+				}
+			}
+			if i+2 < len(tokens) {
+				text, ok := tokens[i+1].(xml.CharData)
+				if _, end := tokens[i+2].(xml.EndElement); ok && end {
+					buf.WriteString(">")
+					_ = xml.EscapeText(&buf, text)
+					buf.WriteString("</" + xmlName(tok.Name) + ">\n")
+					i += 2
+					continue
+				}
+			}
+			buf.WriteString(">\n")
+			depth++
+		case xml.EndElement:
+			depth--
+			indent()
+			buf.WriteString("</" + xmlName(tok.Name) + ">\n")
+		case xml.CharData:
+			// Mixed content.
+			indent()
+			_ = xml.EscapeText(&buf, bytes.TrimSpace(tok))
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeStartElement(buf *bytes.Buffer, e xml.StartElement) {
+	buf.WriteString("<" + xmlName(e.Name))
+	attrs := slices.Clone(e.Attr)
+	slices.SortFunc(attrs, func(a, b xml.Attr) int {
+		return cmp.Or(cmp.Compare(a.Name.Space, b.Name.Space), cmp.Compare(a.Name.Local, b.Name.Local))
+	})
+	for _, a := range attrs {
+		buf.WriteString(" " + xmlName(a.Name) + `="`)
+		_ = xml.EscapeText(buf, []byte(a.Value))
+		buf.WriteString(`"`)
+	}
+}
+
+// xmlName returns the raw name n with its prefix.
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}